@@ -1,5 +1,7 @@
 package plist
 
+import "net/url"
+
 type bplistTrailer struct {
 	Unused            [5]uint8
 	SortVersion       uint8
@@ -10,10 +12,25 @@ type bplistTrailer struct {
 	OffsetTableOffset uint64
 }
 
+// resolveURL parses a URL from a binary property list, resolving it against base if it is not nil.
+func resolveURL(base *url.URL, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u
+}
+
 const (
 	bpTagNull        uint8 = 0x00
 	bpTagBoolFalse   uint8 = 0x08
 	bpTagBoolTrue    uint8 = 0x09
+	bpTagURL         uint8 = 0x0C
+	bpTagURLWithBase uint8 = 0x0D
+	bpTagUUID        uint8 = 0x0E
 	bpTagInteger     uint8 = 0x10
 	bpTagReal        uint8 = 0x20
 	bpTagDate        uint8 = 0x30
@@ -22,5 +39,18 @@ const (
 	bpTagUTF16String uint8 = 0x60
 	bpTagUID         uint8 = 0x80
 	bpTagArray       uint8 = 0xA0
+	bpTagOrderedSet  uint8 = 0xB0
+	bpTagSet         uint8 = 0xC0
 	bpTagDictionary  uint8 = 0xD0
 )
+
+// bplistArrayTag returns the tag for an array, or for a set that is stored like one.
+func bplistArrayTag(set cfSetKind) uint8 {
+	switch set {
+	case cfUnorderedSet:
+		return bpTagSet
+	case cfOrderedSet:
+		return bpTagOrderedSet
+	}
+	return bpTagArray
+}
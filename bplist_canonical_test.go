@@ -0,0 +1,201 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func marshalCanonical(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, BinaryFormat)
+	enc.Canonical()
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+func bplistTrailerOf(t *testing.T, doc []byte) bplistTrailer {
+	t.Helper()
+	var trailer bplistTrailer
+	if err := binary.Read(bytes.NewReader(doc[len(doc)-32:]), binary.BigEndian, &trailer); err != nil {
+		t.Fatal(err)
+	}
+	return trailer
+}
+
+func TestCanonicalBplist(t *testing.T) {
+	// Every byte of this document is fixed by the canonical form: the root dictionary,
+	// its keys in order, the string "b" shared by a key and a value, the array, and
+	// the integer 1 shared by both of its elements.
+	doc, err := marshalCanonical(map[string]any{"b": []any{1, uint8(1)}, "a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "62706c6973743030" + // bplist00
+		"d20102020351615162a204041001" + // objects
+		"080d0f1114" + // offset table
+		"00000000000001010000000000000005" + "00000000000000000000000000000016" // trailer
+	if got := hex.EncodeToString(doc); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestCanonicalBplistIsDeterministic(t *testing.T) {
+	type entry struct {
+		Name  string
+		Count int
+		Tags  []string
+	}
+	value := make(map[string]any)
+	for i := 0; i < 50; i++ {
+		value[fmt.Sprintf("key%d", i)] = entry{Name: "shared", Count: i % 3, Tags: []string{"x", "y"}}
+	}
+	first, err := marshalCanonical(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		again, err := marshalCanonical(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatal("expected identical output from every run")
+		}
+	}
+
+	// The same contents in a different shape encode the same way.
+	asMap := map[string]any{"Name": "shared", "Count": 0, "Tags": []any{"x", "y"}}
+	var ordered bytes.Buffer
+	enc := NewEncoderForFormat(&ordered, BinaryFormat)
+	enc.PreserveKeyOrder()
+	enc.Canonical()
+	if err := enc.Encode(entry{Name: "shared", Tags: []string{"x", "y"}}); err != nil {
+		t.Fatal(err)
+	}
+	if fromMap, _ := marshalCanonical(asMap); !bytes.Equal(fromMap, ordered.Bytes()) {
+		t.Errorf("expected a struct and an equal map to encode the same way:\n%x\n%x", fromMap, ordered.Bytes())
+	}
+
+	var got map[string]entry
+	if _, err := Unmarshal(first, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 50 || !reflect.DeepEqual(got["key4"], value["key4"]) {
+		t.Errorf("unexpected round trip %v", got["key4"])
+	}
+}
+
+func TestCanonicalBplistUniquesContainers(t *testing.T) {
+	value := []any{[]any{"a", true}, []any{"a", true}, map[string]any{"k": "a"}, map[string]any{"k": "a"}}
+	doc, err := marshalCanonical(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The outer array, one inner array, "a", true, one dictionary and "k".
+	if n := bplistTrailerOf(t, doc).NumObjects; n != 6 {
+		t.Errorf("expected 6 objects, got %d", n)
+	}
+	var got []any
+	if _, err := Unmarshal(doc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("expected %v, got %v", value, got)
+	}
+
+	// Sets are not arrays, even with the same members.
+	doc, err = marshalCanonical([]any{[]any{"a"}, Set{"a"}, OrderedSet{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bplistTrailerOf(t, doc).NumObjects; n != 5 {
+		t.Errorf("expected 5 objects, got %d", n)
+	}
+}
+
+func TestCanonicalBplistReferenceSizes(t *testing.T) {
+	// 255 strings and the array that holds them: 256 objects, numbered 0 to 255.
+	strs := make([]string, 255)
+	for i := range strs {
+		strs[i] = fmt.Sprint(i)
+	}
+	doc, err := marshalCanonical(strs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailer := bplistTrailerOf(t, doc)
+	if trailer.NumObjects != 256 || trailer.ObjectRefSize != 1 || trailer.OffsetIntSize != 2 {
+		t.Errorf("expected 256 objects with 1-byte references and 2-byte offsets, got %+v", trailer)
+	}
+	var got []string
+	if _, err := Unmarshal(doc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, strs) {
+		t.Errorf("expected %v, got %v", strs, got)
+	}
+}
+
+func TestBplistUniquingByContent(t *testing.T) {
+	// These have the same CRC-32 checksum.
+	plumless, buckeroo := []byte("plumless"), []byte("buckeroo")
+	if crc32.ChecksumIEEE(plumless) != crc32.ChecksumIEEE(buckeroo) {
+		t.Fatal("expected colliding checksums")
+	}
+	negativeZero := math.Copysign(0, -1)
+
+	for _, canonical := range []bool{false, true} {
+		subtest(t, fmt.Sprint("Canonical=", canonical), func(t *testing.T) {
+			marshal := func(v any) ([]byte, error) { return Marshal(v, BinaryFormat) }
+			if canonical {
+				marshal = marshalCanonical
+			}
+
+			doc, err := marshal([][]byte{plumless, buckeroo})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data [][]byte
+			if _, err := Unmarshal(doc, &data); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data[0], plumless) || !bytes.Equal(data[1], buckeroo) {
+				t.Errorf("expected distinct data, got %q", data)
+			}
+
+			// Data are never mistaken for a string with the same bytes.
+			doc, err = marshal([]any{"plumless", plumless})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var mixed []any
+			if _, err := Unmarshal(doc, &mixed); err != nil {
+				t.Fatal(err)
+			}
+			if mixed[0] != "plumless" || !bytes.Equal(mixed[1].([]byte), plumless) {
+				t.Errorf("expected a string and data, got %#v", mixed)
+			}
+
+			doc, err = marshal([]float64{math.NaN(), math.NaN(), 0, negativeZero})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := bplistTrailerOf(t, doc).NumObjects; n != 4 {
+				t.Errorf("expected one NaN object, got %d objects", n)
+			}
+			var reals []float64
+			if _, err := Unmarshal(doc, &reals); err != nil {
+				t.Fatal(err)
+			}
+			if !math.IsNaN(reals[0]) || !math.IsNaN(reals[1]) || math.Signbit(reals[2]) || !math.Signbit(reals[3]) {
+				t.Errorf("unexpected reals %v", reals)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package plist
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -24,17 +25,25 @@ func bplistMinimumIntSize(n uint64) int {
 
 func bplistValueShouldUnique(pval cfValue) bool {
 	switch pval.(type) {
-	case cfString, *cfNumber, *cfReal, cfDate, cfData:
+	case cfString, *cfNumber, *cfReal, cfDate, cfData, cfNull, cfURL, cfUUID:
 		return true
 	}
 	return false
 }
 
 type bplistGenerator struct {
-	writer   *countedWriter
-	objmap   map[any]uint64 // maps pValue.hash()es to object locations
-	objtable []cfValue
-	trailer  bplistTrailer
+	writer    *countedWriter
+	objmap    map[any]uint64 // maps pValue.hash()es to object locations
+	objtable  []cfValue
+	trailer   bplistTrailer
+	keepOrder bool
+	precision time.Duration
+
+	canonical  bool
+	contentIDs map[string]int  // canonical: maps the content key of each distinct value to its content ID
+	containers map[cfValue]int // canonical: content IDs of arrays and dictionaries, by identity
+	objects    map[int]uint64  // canonical: maps content IDs to object locations
+	scratch    bytes.Buffer    // canonical: holds scalars while their content keys are built
 }
 
 func (p *bplistGenerator) flattenPlistValue(pval cfValue) {
@@ -45,12 +54,16 @@ func (p *bplistGenerator) flattenPlistValue(pval cfValue) {
 		}
 	}
 
+	if dict, ok := pval.(*cfDictionary); ok && !p.keepOrder {
+		// The sorted copy is filed under the original's hash, where references to it will look.
+		pval = dict.sorted()
+	}
+
 	p.objmap[key] = uint64(len(p.objtable))
 	p.objtable = append(p.objtable, pval)
 
 	switch pval := pval.(type) {
 	case *cfDictionary:
-		pval.sort()
 		for _, k := range pval.keys {
 			p.flattenPlistValue(cfString(k))
 		}
@@ -64,18 +77,111 @@ func (p *bplistGenerator) flattenPlistValue(pval cfValue) {
 	}
 }
 
+// contentID returns a number identifying pval by its contents: two values have the same content ID
+// exactly when they would be written as the same object. A scalar's content key is its encoding;
+// an array's or dictionary's is its marker followed by the content IDs of its members, in the
+// order they are written.
+func (p *bplistGenerator) contentID(pval cfValue) int {
+	var key []byte
+	switch v := pval.(type) {
+	case *cfDictionary:
+		if id, ok := p.containers[pval]; ok {
+			return id
+		}
+		v = v.sorted()
+		key = binary.AppendUvarint([]byte{bpTagDictionary}, uint64(len(v.keys)))
+		for _, k := range v.keys {
+			key = binary.AppendUvarint(key, uint64(p.contentID(cfString(k))))
+		}
+		for _, sval := range v.values {
+			key = binary.AppendUvarint(key, uint64(p.contentID(sval)))
+		}
+	case *cfArray:
+		if id, ok := p.containers[pval]; ok {
+			return id
+		}
+		key = binary.AppendUvarint([]byte{bplistArrayTag(v.set)}, uint64(len(v.values)))
+		for _, sval := range v.values {
+			key = binary.AppendUvarint(key, uint64(p.contentID(sval)))
+		}
+	default:
+		// No scalar's encoding begins with an array, set or dictionary marker.
+		saved := p.writer
+		p.scratch.Reset()
+		p.writer = &countedWriter{Writer: &p.scratch}
+		p.writePlistValue(pval)
+		p.writer = saved
+		key = p.scratch.Bytes()
+	}
+
+	id, ok := p.contentIDs[string(key)]
+	if !ok {
+		id = len(p.contentIDs)
+		p.contentIDs[string(key)] = id
+	}
+	switch pval.(type) {
+	case *cfDictionary, *cfArray:
+		p.containers[pval] = id
+	}
+	return id
+}
+
+// flattenCanonical is flattenPlistValue for canonical documents: every value, containers
+// included, shares one object with every other value that has the same contents.
+func (p *bplistGenerator) flattenCanonical(pval cfValue) {
+	id := p.contentID(pval)
+	if _, ok := p.objects[id]; ok {
+		return
+	}
+	if dict, ok := pval.(*cfDictionary); ok {
+		pval = dict.sorted()
+	}
+
+	p.objects[id] = uint64(len(p.objtable))
+	p.objtable = append(p.objtable, pval)
+
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		for _, k := range pval.keys {
+			p.flattenCanonical(cfString(k))
+		}
+		for _, v := range pval.values {
+			p.flattenCanonical(v)
+		}
+	case *cfArray:
+		for _, v := range pval.values {
+			p.flattenCanonical(v)
+		}
+	}
+}
+
 func (p *bplistGenerator) indexForPlistValue(pval cfValue) (uint64, bool) {
+	if p.canonical {
+		v, ok := p.objects[p.contentID(pval)]
+		return v, ok
+	}
 	v, ok := p.objmap[pval.hash()]
 	return v, ok
 }
 
 func (p *bplistGenerator) generateDocument(root cfValue) {
 	p.objtable = make([]cfValue, 0, 16)
-	p.objmap = make(map[any]uint64)
-	p.flattenPlistValue(root)
+	if p.canonical {
+		p.contentIDs = make(map[string]int)
+		p.containers = make(map[cfValue]int)
+		p.objects = make(map[int]uint64)
+		p.flattenCanonical(root)
+	} else {
+		p.objmap = make(map[any]uint64)
+		p.flattenPlistValue(root)
+	}
 
 	p.trailer.NumObjects = uint64(len(p.objtable))
 	p.trailer.ObjectRefSize = uint8(bplistMinimumIntSize(p.trailer.NumObjects))
+	if p.canonical {
+		// References need only reach the last object.
+		p.trailer.ObjectRefSize = uint8(bplistMinimumIntSize(p.trailer.NumObjects - 1))
+	}
 
 	p.writer.Write([]byte("bplist00"))
 
@@ -86,7 +192,11 @@ func (p *bplistGenerator) generateDocument(root cfValue) {
 	}
 
 	p.trailer.OffsetIntSize = uint8(bplistMinimumIntSize(uint64(p.writer.BytesWritten())))
-	p.trailer.TopObject = p.objmap[root.hash()]
+	if p.canonical {
+		// Offsets need only reach the last object.
+		p.trailer.OffsetIntSize = uint8(bplistMinimumIntSize(offtable[len(offtable)-1]))
+	}
+	p.trailer.TopObject, _ = p.indexForPlistValue(root)
 	p.trailer.OffsetTableOffset = uint64(p.writer.BytesWritten())
 
 	for _, offset := range offtable {
@@ -105,11 +215,15 @@ func (p *bplistGenerator) writePlistValue(pval cfValue) {
 	case *cfDictionary:
 		p.writeDictionaryTag(pval)
 	case *cfArray:
-		p.writeArrayTag(pval.values)
+		p.writeArrayTag(pval)
 	case cfString:
 		p.writeStringTag(string(pval))
 	case *cfNumber:
-		p.writeIntTag(pval.signed, pval.value)
+		if pval.hi != 0 {
+			p.writeInt128Tag(pval.int128())
+		} else {
+			p.writeIntTag(pval.signed, pval.value)
+		}
 	case *cfReal:
 		if pval.wide {
 			p.writeRealTag(pval.value, 64)
@@ -124,6 +238,15 @@ func (p *bplistGenerator) writePlistValue(pval cfValue) {
 		p.writeDateTag(time.Time(pval))
 	case cfUID:
 		p.writeUIDTag(UID(pval))
+	case cfNull:
+		binary.Write(p.writer, binary.BigEndian, bpTagNull)
+	case cfURL:
+		// The URL's string is written inline, rather than as an object of its own.
+		binary.Write(p.writer, binary.BigEndian, bpTagURL)
+		p.writeStringTag(string(pval))
+	case cfUUID:
+		binary.Write(p.writer, binary.BigEndian, bpTagUUID)
+		binary.Write(p.writer, binary.BigEndian, pval[:])
 	default:
 		panic(fmt.Errorf("unknown plist type %t", pval))
 	}
@@ -180,15 +303,19 @@ func (p *bplistGenerator) writeIntTag(signed bool, n uint64) {
 
 	binary.Write(p.writer, binary.BigEndian, tag)
 	if tag&0xF == 0x4 {
-		// SInt128; in the absence of true 128-bit integers in Go,
-		// we'll just fake the top half. We only got here because
-		// we had an unsigned 64-bit int that didn't fit,
-		// so sign extend it with zeroes.
+		// SInt128; we only got here because we had an unsigned
+		// 64-bit int that didn't fit, so its top half is zero.
 		binary.Write(p.writer, binary.BigEndian, uint64(0))
 	}
 	binary.Write(p.writer, binary.BigEndian, val)
 }
 
+func (p *bplistGenerator) writeInt128Tag(i Int128) {
+	binary.Write(p.writer, binary.BigEndian, uint8(bpTagInteger|0x4))
+	binary.Write(p.writer, binary.BigEndian, i.Hi)
+	binary.Write(p.writer, binary.BigEndian, i.Lo)
+}
+
 func (p *bplistGenerator) writeUIDTag(u UID) {
 	nbytes := bplistMinimumIntSize(uint64(u))
 	tag := bpTagUID | uint8((nbytes - 1))
@@ -211,8 +338,7 @@ func (p *bplistGenerator) writeRealTag(n float64, bits int) {
 
 func (p *bplistGenerator) writeDateTag(t time.Time) {
 	tag := uint8(bpTagDate) | 0x3
-	val := float64(t.In(time.UTC).UnixNano()) / float64(time.Second)
-	val -= 978307200 // Adjust to Apple Epoch
+	val := appleSeconds(t.Truncate(p.precision))
 
 	binary.Write(p.writer, binary.BigEndian, tag)
 	binary.Write(p.writer, binary.BigEndian, val)
@@ -253,13 +379,13 @@ func (p *bplistGenerator) writeStringTag(str string) {
 }
 
 func (p *bplistGenerator) writeDictionaryTag(dict *cfDictionary) {
-	// assumption: sorted already; flattenPlistValue did this.
+	// assumption: ordered already; flattenPlistValue did this.
 	cnt := len(dict.keys)
 	p.writeCountedTag(bpTagDictionary, uint64(cnt))
 	vals := make([]uint64, cnt*2)
 	for i, k := range dict.keys {
 		// invariant: keys have already been "uniqued" (as PStrings)
-		keyIdx, ok := p.objmap[cfString(k).hash()]
+		keyIdx, ok := p.indexForPlistValue(cfString(k))
 		if !ok {
 			panic(errors.New("failed to find key " + k + " in object map during serialization"))
 		}
@@ -280,9 +406,9 @@ func (p *bplistGenerator) writeDictionaryTag(dict *cfDictionary) {
 	}
 }
 
-func (p *bplistGenerator) writeArrayTag(arr []cfValue) {
-	p.writeCountedTag(bpTagArray, uint64(len(arr)))
-	for _, v := range arr {
+func (p *bplistGenerator) writeArrayTag(arr *cfArray) {
+	p.writeCountedTag(bplistArrayTag(arr.set), uint64(len(arr.values)))
+	for _, v := range arr.values {
 		objIdx, ok := p.indexForPlistValue(v)
 		if !ok {
 			panic(errors.New("failed to find value in object map during serialization"))
@@ -296,6 +422,22 @@ func (p *bplistGenerator) Indent(i string) {
 	// There's nothing to indent.
 }
 
+func (p *bplistGenerator) PreserveKeyOrder() {
+	p.keepOrder = true
+}
+
+func (p *bplistGenerator) DatePrecision(d time.Duration) {
+	p.precision = d
+}
+
+func (p *bplistGenerator) PreserveFloat32() {
+	// Binary property lists always keep 32-bit reals.
+}
+
+func (p *bplistGenerator) Canonical() {
+	p.canonical = true
+}
+
 func newBplistGenerator(w io.Writer) *bplistGenerator {
 	return &bplistGenerator{
 		writer: &countedWriter{Writer: mustWriter{w}},
@@ -0,0 +1,194 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var markerUUID = UUID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+// singleObjectBplist wraps one encoded object in a bplist00 document.
+func singleObjectBplist(object []byte) []byte {
+	doc := append([]byte("bplist00"), object...)
+	offsetTable := len(doc)
+	doc = append(doc, 8)
+	trailer := bplistTrailer{
+		OffsetIntSize:     1,
+		ObjectRefSize:     1,
+		NumObjects:        1,
+		TopObject:         0,
+		OffsetTableOffset: uint64(offsetTable),
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, trailer)
+	return append(doc, buf.Bytes()...)
+}
+
+func TestBplistMarkers(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a/b?q=1")
+	value := map[string]any{
+		"Null":    NewNull(),
+		"URL":     u,
+		"UUID":    markerUUID,
+		"Set":     Set{"a", "b"},
+		"Ordered": OrderedSet{uint64(1), uint64(2)},
+	}
+
+	doc, err := Marshal(value, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtest(t, "Any", func(t *testing.T) {
+		var got map[string]any
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]any{
+			"Null":    nil,
+			"URL":     u,
+			"UUID":    markerUUID,
+			"Set":     Set{"a", "b"},
+			"Ordered": OrderedSet{uint64(1), uint64(2)},
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %#v, got %#v", expected, got)
+		}
+	})
+
+	subtest(t, "Typed", func(t *testing.T) {
+		var got struct {
+			Null    *string
+			URL     url.URL
+			UUID    UUID
+			Set     []string
+			Ordered []int
+		}
+		got.Null = new(string)
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Null != nil || got.URL.String() != u.String() || got.UUID != markerUUID ||
+			!reflect.DeepEqual(got.Set, []string{"a", "b"}) || !reflect.DeepEqual(got.Ordered, []int{1, 2}) {
+			t.Errorf("unexpected result %+v", got)
+		}
+	})
+
+	subtest(t, "Value", func(t *testing.T) {
+		var v Value
+		if _, err := Unmarshal(doc, &v); err != nil {
+			t.Fatal(err)
+		}
+		kinds := map[string]Kind{"Null": NullKind, "URL": URLKind, "UUID": UUIDKind, "Set": SetKind}
+		for key, kind := range kinds {
+			if got, _ := v.Lookup(key); got.Kind() != kind {
+				t.Errorf("%s: expected kind %v, got %v", key, kind, got.Kind())
+			}
+		}
+		urlValue, _ := v.Lookup("URL")
+		if got, ok := urlValue.AsURL(); !ok || got.String() != u.String() {
+			t.Errorf("expected URL %v, got %v", u, got)
+		}
+		uuidValue, _ := v.Lookup("UUID")
+		if got, ok := uuidValue.AsUUID(); !ok || got != markerUUID {
+			t.Errorf("expected UUID %v, got %v", markerUUID, got)
+		}
+
+		again, err := Marshal(v, BinaryFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, doc) {
+			t.Errorf("re-encoding the Value changed the document")
+		}
+	})
+}
+
+func TestBplistMarkerFallbacks(t *testing.T) {
+	u, _ := url.Parse("https://example.com/")
+	value := map[string]any{
+		"Null": NewNull(),
+		"URL":  u,
+		"UUID": markerUUID,
+		"Set":  Set{"a"},
+	}
+
+	for _, format := range []int{XMLFormat, OpenStepFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(value, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if _, err := Unmarshal(doc, &got); err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]any{
+				"Null": "",
+				"URL":  u.String(),
+				"UUID": markerUUID.String(),
+				"Set":  []any{"a"},
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %#v, got %#v", expected, got)
+			}
+
+			var typed struct {
+				URL  *url.URL
+				UUID UUID
+			}
+			if _, err := Unmarshal(doc, &typed); err != nil {
+				t.Fatal(err)
+			}
+			if typed.URL == nil || typed.URL.String() != u.String() || typed.UUID != markerUUID {
+				t.Errorf("unexpected result %+v", typed)
+			}
+		})
+	}
+}
+
+func TestBplistURLWithBase(t *testing.T) {
+	// A URL relative to a URL relative to an absolute URL.
+	object := []byte{bpTagURLWithBase, bpTagURLWithBase, bpTagURL}
+	for _, s := range []string{"http://e.co/a/", "b/", "c?d"} {
+		object = append(object, bpTagASCIIString|uint8(len(s)))
+		object = append(object, s...)
+	}
+
+	var got url.URL
+	if _, err := Unmarshal(singleObjectBplist(object), &got); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://e.co/a/b/c?d"; got.String() != expected {
+		t.Errorf("expected %s, got %v", expected, &got)
+	}
+
+	// A base must be a URL.
+	bad := []byte{bpTagURLWithBase, bpTagNull}
+	if _, err := Unmarshal(singleObjectBplist(bad), &got); err == nil {
+		t.Error("expected an error for a URL whose base is not a URL")
+	}
+}
+
+func TestUUIDText(t *testing.T) {
+	const canonical = "12345678-9ABC-DEF0-0123-456789ABCDEF"
+	if s := markerUUID.String(); s != canonical {
+		t.Errorf("expected %s, got %s", canonical, s)
+	}
+
+	var u UUID
+	if err := u.UnmarshalText([]byte(strings.ToLower(canonical))); err != nil || u != markerUUID {
+		t.Errorf("expected %v, got %v (%v)", markerUUID, u, err)
+	}
+
+	for _, bad := range []string{"", "12345678-9ABC-DEF0-0123-456789ABCDE", "123456789-ABC-DEF0-0123-456789ABCDEF", "12345678-9ABC-DEF0-0123-456789ABCDEG"} {
+		if err := u.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
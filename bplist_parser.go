@@ -7,8 +7,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/url"
 	"runtime"
-	"time"
 	"unicode/utf16"
 )
 
@@ -19,13 +19,15 @@ const (
 type offset uint64
 
 type bplistParser struct {
-	buffer []byte
+	source bplistSource
 
-	reader        io.ReadSeeker
+	reader        io.Reader
 	version       int
-	objects       []cfValue // object ID to object
+	objects       []cfValue          // object ID to object
+	sparse        map[uint64]cfValue // used in place of objects when only part of the document is parsed
 	trailer       bplistTrailer
 	trailerOffset uint64
+	limits        *limitTracker
 
 	containerStack []offset // slice of object offsets; manipulated during container deserialization
 }
@@ -72,31 +74,47 @@ func (p *bplistParser) parseDocument() (pval cfValue, parseError error) {
 		}
 	}()
 
-	p.buffer, _ = io.ReadAll(p.reader)
+	buffer := p.limits.readAll(p.reader)
+	p.source = bplistBuffer(buffer)
+	p.parseHeader()
+	p.objects = make([]cfValue, p.trailer.NumObjects)
 
-	l := len(p.buffer)
-	if l < 40 {
+	pval = p.objectAtIndex(p.trailer.TopObject)
+	return
+}
+
+// parseHeader checks the magic number and reads and validates the trailer.
+func (p *bplistParser) parseHeader() {
+	l := p.source.size()
+	if l < 8 {
 		panic(errors.New("not enough data"))
 	}
 
-	if !bytes.Equal(p.buffer[0:6], []byte{'b', 'p', 'l', 'i', 's', 't'}) {
+	header := p.bytes(0, 8)
+	if !bytes.Equal(header[0:6], []byte{'b', 'p', 'l', 'i', 's', 't'}) {
 		panic(errors.New("incomprehensible magic"))
 	}
 
-	p.version = int(((p.buffer[6] - '0') * 10) + (p.buffer[7] - '0'))
+	p.version = int(((header[6] - '0') * 10) + (header[7] - '0'))
 
 	if p.version > 1 {
-		panic(fmt.Errorf("unexpected version %d", p.version))
+		// Newer versions, such as bplist15 to bplist17, are undocumented.
+		panic(fmt.Errorf("unsupported version %q", header[6:8]))
 	}
 
-	p.trailerOffset = uint64(l - 32)
+	if l < 40 {
+		panic(errors.New("not enough data"))
+	}
+
+	p.trailerOffset = l - 32
+	trailer := p.bytes(offset(p.trailerOffset), 32)
 	p.trailer = bplistTrailer{
-		SortVersion:       p.buffer[p.trailerOffset+5],
-		OffsetIntSize:     p.buffer[p.trailerOffset+6],
-		ObjectRefSize:     p.buffer[p.trailerOffset+7],
-		NumObjects:        binary.BigEndian.Uint64(p.buffer[p.trailerOffset+8:]),
-		TopObject:         binary.BigEndian.Uint64(p.buffer[p.trailerOffset+16:]),
-		OffsetTableOffset: binary.BigEndian.Uint64(p.buffer[p.trailerOffset+24:]),
+		SortVersion:       trailer[5],
+		OffsetIntSize:     trailer[6],
+		ObjectRefSize:     trailer[7],
+		NumObjects:        binary.BigEndian.Uint64(trailer[8:]),
+		TopObject:         binary.BigEndian.Uint64(trailer[16:]),
+		OffsetTableOffset: binary.BigEndian.Uint64(trailer[24:]),
 	}
 
 	p.validateDocumentTrailer()
@@ -108,37 +126,54 @@ func (p *bplistParser) parseDocument() (pval cfValue, parseError error) {
 	// - Object IDs are big enough to support the number of objects in this plist
 	// - Top object is in range
 
-	p.objects = make([]cfValue, p.trailer.NumObjects)
+	p.limits.checkObjects(p.trailer.NumObjects)
+}
 
-	pval = p.objectAtIndex(p.trailer.TopObject)
-	return
+// bytes returns the n bytes of the document starting at off.
+func (p *bplistParser) bytes(off offset, n uint64) []byte {
+	return p.source.bytes(off, n)
+}
+
+func (p *bplistParser) byteAt(off offset) byte {
+	return p.source.bytes(off, 1)[0]
 }
 
 // parseSizedInteger returns a 128-bit integer as low64, high64
 func (p *bplistParser) parseSizedInteger(off offset, nbytes int) (lo uint64, hi uint64, newOffset offset) {
+	switch nbytes {
+	case 1, 2, 4, 8, 16:
+	default:
+		panic(errors.New("illegal integer size"))
+	}
+	lo, hi = sizedInteger(p.bytes(off, uint64(nbytes)))
+	newOffset = off + offset(nbytes)
+	return
+}
+
+// sizedInteger decodes a big-endian integer of 1, 2, 4, 8 or 16 bytes.
+func sizedInteger(b []byte) (lo uint64, hi uint64) {
 	// Per comments in CoreFoundation, format version 00 requires that all
 	// 1, 2 or 4-byte integers be interpreted as unsigned. 8-byte integers are
 	// signed (always?) and therefore must be sign extended here.
 	// negative 1, 2, or 4-byte integers are always emitted as 64-bit.
-	switch nbytes {
+	switch len(b) {
 	case 1:
-		lo, hi = uint64(p.buffer[off]), 0
+		lo, hi = uint64(b[0]), 0
 	case 2:
-		lo, hi = uint64(binary.BigEndian.Uint16(p.buffer[off:])), 0
+		lo, hi = uint64(binary.BigEndian.Uint16(b)), 0
 	case 4:
-		lo, hi = uint64(binary.BigEndian.Uint32(p.buffer[off:])), 0
+		lo, hi = uint64(binary.BigEndian.Uint32(b)), 0
 	case 8:
-		lo = binary.BigEndian.Uint64(p.buffer[off:])
-		if p.buffer[off]&0x80 != 0 {
+		lo = binary.BigEndian.Uint64(b)
+		if b[0]&0x80 != 0 {
 			// sign extend if lo is signed
 			hi = signedHighBits
 		}
 	case 16:
-		lo, hi = binary.BigEndian.Uint64(p.buffer[off+8:]), binary.BigEndian.Uint64(p.buffer[off:])
+		lo, hi = binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b)
 	default:
 		panic(errors.New("illegal integer size"))
 	}
-	newOffset = off + offset(nbytes)
 	return
 }
 
@@ -157,19 +192,38 @@ func (p *bplistParser) objectAtIndex(index uint64) cfValue {
 		panic(fmt.Errorf("invalid object#%d (max %d)", index, p.trailer.NumObjects))
 	}
 
-	if pval := p.objects[index]; pval != nil {
+	if p.objects != nil {
+		if pval := p.objects[index]; pval != nil {
+			return pval
+		}
+	} else if pval, ok := p.sparse[index]; ok {
 		return pval
 	}
+	p.limits.tick()
+
+	pval := p.parseTagAtOffset(p.objectOffset(index))
+	if p.objects != nil {
+		p.objects[index] = pval
+	} else {
+		if p.sparse == nil {
+			p.sparse = make(map[uint64]cfValue)
+		}
+		p.sparse[index] = pval
+	}
+	return pval
+}
+
+// objectOffset looks up the offset of an object in the offset table.
+func (p *bplistParser) objectOffset(index uint64) offset {
+	if index >= p.trailer.NumObjects {
+		panic(fmt.Errorf("invalid object#%d (max %d)", index, p.trailer.NumObjects))
+	}
 
 	off, _ := p.parseOffsetAtOffset(offset(p.trailer.OffsetTableOffset + (index * uint64(p.trailer.OffsetIntSize))))
 	if off > offset(p.trailer.OffsetTableOffset-1) {
 		panic(fmt.Errorf("object#%d starts beyond beginning of object table (%#x, table@%#x)", index, off, p.trailer.OffsetTableOffset))
 	}
-
-	pval := p.parseTagAtOffset(off)
-	p.objects[index] = pval
-	return pval
-
+	return off
 }
 
 func (p *bplistParser) pushNestedObject(off offset) {
@@ -178,6 +232,7 @@ func (p *bplistParser) pushNestedObject(off offset) {
 			p.panicNestedObject(off)
 		}
 	}
+	p.limits.enter()
 	p.containerStack = append(p.containerStack, off)
 }
 
@@ -192,46 +247,45 @@ func (p *bplistParser) panicNestedObject(off offset) {
 }
 
 func (p *bplistParser) popNestedObject() {
+	p.limits.leave()
 	p.containerStack = p.containerStack[:len(p.containerStack)-1]
 }
 
 func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 
 	switch tag & 0xF0 {
 	case bpTagNull:
-		switch tag & 0x0F {
+		switch tag {
+		case bpTagNull:
+			return cfNull{}
 		case bpTagBoolTrue, bpTagBoolFalse:
 			return cfBoolean(tag == bpTagBoolTrue)
+		case bpTagURL, bpTagURLWithBase:
+			return p.parseURLAtOffset(off)
+		case bpTagUUID:
+			var uuid cfUUID
+			copy(uuid[:], p.bytes(off+1, 16))
+			return uuid
 		}
 	case bpTagInteger:
+		// Integers are signed 128-bit integers, sign-extended from however many bytes they take up.
 		lo, hi, _ := p.parseIntegerAtOffset(off)
-		return &cfNumber{
-			signed: hi == signedHighBits, // a signed integer is stored as a 128-bit integer with the top 64 bits set
-			value:  lo,
-		}
+		return newInt128Number(Int128{Hi: int64(hi), Lo: lo})
 	case bpTagReal:
 		nbytes := 1 << (tag & 0x0F)
 		switch nbytes {
 		case 4:
-			bits := binary.BigEndian.Uint32(p.buffer[off+1:])
+			bits := binary.BigEndian.Uint32(p.bytes(off+1, 4))
 			return &cfReal{wide: false, value: float64(math.Float32frombits(bits))}
 		case 8:
-			bits := binary.BigEndian.Uint64(p.buffer[off+1:])
+			bits := binary.BigEndian.Uint64(p.bytes(off+1, 8))
 			return &cfReal{wide: true, value: math.Float64frombits(bits)}
 		}
 		panic(errors.New("illegal float size"))
 	case bpTagDate:
-		bits := binary.BigEndian.Uint64(p.buffer[off+1:])
-		val := math.Float64frombits(bits)
-
-		// Apple Epoch is 20110101000000Z
-		// Adjust for UNIX Time
-		val += 978307200
-
-		sec, fsec := math.Modf(val)
-		time := time.Unix(int64(sec), int64(fsec*float64(time.Second))).In(time.UTC)
-		return cfDate(time)
+		bits := binary.BigEndian.Uint64(p.bytes(off+1, 8))
+		return cfDate(timeFromAppleSeconds(math.Float64frombits(bits)))
 	case bpTagData:
 		data := p.parseDataAtOffset(off)
 		return cfData(data)
@@ -248,17 +302,25 @@ func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
 		return p.parseDictionaryAtOffset(off)
 	case bpTagArray:
 		return p.parseArrayAtOffset(off)
+	case bpTagSet:
+		arr := p.parseArrayAtOffset(off)
+		arr.set = cfUnorderedSet
+		return arr
+	case bpTagOrderedSet:
+		arr := p.parseArrayAtOffset(off)
+		arr.set = cfOrderedSet
+		return arr
 	}
 	panic(fmt.Errorf("unexpected atom %#x2.02x at offset %#x", tag, off))
 }
 
 func (p *bplistParser) parseIntegerAtOffset(off offset) (uint64, uint64, offset) {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 	return p.parseSizedInteger(off+1, 1<<(tag&0xF))
 }
 
 func (p *bplistParser) countForTagAtOffset(off offset) (uint64, offset) {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 	cnt := uint64(tag & 0x0F)
 	if cnt == 0xF {
 		cnt, _, off = p.parseIntegerAtOffset(off + 1)
@@ -272,7 +334,8 @@ func (p *bplistParser) parseDataAtOffset(off offset) []byte {
 	if start+offset(len) > offset(p.trailer.OffsetTableOffset) {
 		panic(fmt.Errorf("data@%#x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start)))
 	}
-	return p.buffer[start : start+offset(len)]
+	p.limits.countBytes(len)
+	return p.bytes(start, len)
 }
 
 func (p *bplistParser) parseASCIIStringAtOffset(off offset) string {
@@ -280,8 +343,9 @@ func (p *bplistParser) parseASCIIStringAtOffset(off offset) string {
 	if start+offset(len) > offset(p.trailer.OffsetTableOffset) {
 		panic(fmt.Errorf("ascii string@%#x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start)))
 	}
+	p.limits.countBytes(len)
 
-	return zeroCopy8BitString(p.buffer, int(start), int(len))
+	return zeroCopy8BitString(p.bytes(start, len), 0, int(len))
 }
 
 func (p *bplistParser) parseUTF16StringAtOffset(off offset) string {
@@ -290,25 +354,63 @@ func (p *bplistParser) parseUTF16StringAtOffset(off offset) string {
 	if start+offset(bytes) > offset(p.trailer.OffsetTableOffset) {
 		panic(fmt.Errorf("utf16 string@%#x too long (%v bytes, max is %v)", off, bytes, p.trailer.OffsetTableOffset-uint64(start)))
 	}
+	p.limits.countBytes(bytes)
 
+	buf := p.bytes(start, bytes)
 	u16s := make([]uint16, len)
-	for i := offset(0); i < offset(len); i++ {
-		u16s[i] = binary.BigEndian.Uint16(p.buffer[start+(i*2):])
+	for i := uint64(0); i < len; i++ {
+		u16s[i] = binary.BigEndian.Uint16(buf[i*2:])
 	}
 	runes := utf16.Decode(u16s)
 	return string(runes)
 }
 
+// parseURLAtOffset parses a URL. A URL's string follows its tag inline; a URL with a base
+// URL has the base, also inline, in between. The base may have a base of its own, so
+// the tags of a chain of n URLs come first, followed by their n strings, innermost first.
+func (p *bplistParser) parseURLAtOffset(off offset) cfURL {
+	next := off
+	bases := 0
+	for p.byteAt(next) == bpTagURLWithBase {
+		bases++
+		next++
+	}
+	if p.byteAt(next) != bpTagURL {
+		panic(fmt.Errorf("URL@%#x has a base that is not a URL", off))
+	}
+	next++
+
+	var u *url.URL
+	for i := 0; i <= bases; i++ {
+		var str string
+		switch p.byteAt(next) & 0xF0 {
+		case bpTagASCIIString:
+			str = p.parseASCIIStringAtOffset(next)
+			cnt, start := p.countForTagAtOffset(next)
+			next = start + offset(cnt)
+		case bpTagUTF16String:
+			str = p.parseUTF16StringAtOffset(next)
+			cnt, start := p.countForTagAtOffset(next)
+			next = start + offset(cnt*2)
+		default:
+			panic(fmt.Errorf("URL@%#x does not contain a string", off))
+		}
+		u = resolveURL(u, str)
+	}
+	return cfURL(u.String())
+}
+
 func (p *bplistParser) parseObjectListAtOffset(off offset, count uint64) []cfValue {
 	if off+offset(count*uint64(p.trailer.ObjectRefSize)) > offset(p.trailer.OffsetTableOffset) {
 		panic(fmt.Errorf("list@%#x length (%v) puts its end beyond the offset table at %#x", off, count, p.trailer.OffsetTableOffset))
 	}
 	objects := make([]cfValue, count)
 
-	next := off
-	var oid uint64
+	refSize := uint64(p.trailer.ObjectRefSize)
+	refs := p.bytes(off, count*refSize)
 	for i := uint64(0); i < count; i++ {
-		oid, next = p.parseObjectRefAtOffset(next)
+		p.limits.tick()
+		oid, _ := sizedInteger(refs[i*refSize : (i+1)*refSize])
 		objects[i] = p.objectAtIndex(oid)
 	}
 
@@ -321,6 +423,7 @@ func (p *bplistParser) parseDictionaryAtOffset(off offset) *cfDictionary {
 
 	// a dictionary is an object list of [key key key val val val]
 	cnt, start := p.countForTagAtOffset(off)
+	p.limits.checkLength(cnt)
 	objects := p.parseObjectListAtOffset(start, cnt*2)
 
 	keys := make([]string, cnt)
@@ -344,9 +447,10 @@ func (p *bplistParser) parseArrayAtOffset(off offset) *cfArray {
 
 	// an array is just an object list
 	cnt, start := p.countForTagAtOffset(off)
-	return &cfArray{p.parseObjectListAtOffset(start, cnt)}
+	p.limits.checkLength(cnt)
+	return &cfArray{values: p.parseObjectListAtOffset(start, cnt)}
 }
 
-func newBplistParser(r io.ReadSeeker) *bplistParser {
+func newBplistParser(r io.Reader) *bplistParser {
 	return &bplistParser{reader: r}
 }
@@ -0,0 +1,358 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+)
+
+// bplistSource gives the binary parser random access to a document.
+// Slices returned by bytes must not be modified.
+type bplistSource interface {
+	bytes(off offset, n uint64) []byte
+	size() uint64
+}
+
+// bplistBuffer is a document held entirely in memory.
+type bplistBuffer []byte
+
+func (b bplistBuffer) bytes(off offset, n uint64) []byte {
+	checkSourceRange(off, n, uint64(len(b)))
+	return b[off : uint64(off)+n]
+}
+
+func (b bplistBuffer) size() uint64 {
+	return uint64(len(b))
+}
+
+// bplistReaderAt reads a document on demand; every call to bytes is a separate read.
+type bplistReaderAt struct {
+	r io.ReaderAt
+	n uint64
+}
+
+func (s bplistReaderAt) bytes(off offset, n uint64) []byte {
+	checkSourceRange(off, n, s.n)
+	buf := make([]byte, n)
+	if read, err := s.r.ReadAt(buf, int64(off)); read < len(buf) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		panic(err)
+	}
+	return buf
+}
+
+func (s bplistReaderAt) size() uint64 {
+	return s.n
+}
+
+func checkSourceRange(off offset, n uint64, size uint64) {
+	if n > size || uint64(off) > size-n {
+		panic(fmt.Errorf("read of %d bytes at %#x runs past the end of the document (%d bytes)", n, off, size))
+	}
+}
+
+// A BinaryReader gives random access to a binary property list without reading all of it.
+//
+// Objects are read through the document's offset table as they are needed, so looking
+// up a single key, or walking through a long array one element at a time, only touches
+// the parts of the document involved. This makes a BinaryReader suitable for very large
+// documents, such as a music library, read from an *os.File or a memory-mapped file.
+//
+// A BinaryReader does not cache the objects it reads. It is safe for concurrent use if
+// the underlying io.ReaderAt is.
+type BinaryReader struct {
+	source  bplistSource
+	header  bplistParser // version and trailer, as validated by NewBinaryReader
+	options DecoderOptions
+}
+
+// NewBinaryReader returns a BinaryReader for the binary property list held in the
+// first size bytes of r. It reads and validates the document's header and trailer.
+func NewBinaryReader(r io.ReaderAt, size int64) (*BinaryReader, error) {
+	return NewBinaryReaderOptions(r, size, DecoderOptions{})
+}
+
+// NewBinaryReaderOptions is like NewBinaryReader, but enforces the limits in opts
+// on the document as a whole (MaxObjects) and on every value materialized from it.
+func NewBinaryReaderOptions(r io.ReaderAt, size int64, opts DecoderOptions) (*BinaryReader, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("plist: invalid document size %d", size)
+	}
+	br := &BinaryReader{source: bplistReaderAt{r: r, n: uint64(size)}, options: opts}
+	err := br.parse(func(p *bplistParser) {
+		p.parseHeader()
+		br.header = bplistParser{version: p.version, trailer: p.trailer, trailerOffset: p.trailerOffset}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// parse runs f with a new parser over the document, recovering any error it panics with.
+func (r *BinaryReader) parse(f func(p *bplistParser)) (parseError error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			if _, ok := rec.(runtime.Error); ok {
+				panic(rec)
+			}
+			parseError = plistParseError{"binary", rec.(error)}
+		}
+	}()
+
+	p := &bplistParser{
+		source:        r.source,
+		version:       r.header.version,
+		trailer:       r.header.trailer,
+		trailerOffset: r.header.trailerOffset,
+		limits:        newLimitTracker(r.options, cancellation{}),
+	}
+	f(p)
+	return nil
+}
+
+// Root returns the document's top object.
+func (r *BinaryReader) Root() (BinaryObject, error) {
+	return r.object(r.header.trailer.TopObject)
+}
+
+// object reads the tag (and, for a collection, the length) of an object.
+func (r *BinaryReader) object(id uint64) (o BinaryObject, err error) {
+	err = r.parse(func(p *bplistParser) {
+		o = p.lazyObject(r, id)
+	})
+	return
+}
+
+func (p *bplistParser) lazyObject(r *BinaryReader, id uint64) BinaryObject {
+	off := p.objectOffset(id)
+	o := BinaryObject{r: r, id: id}
+
+	tag := p.byteAt(off)
+	switch tag & 0xF0 {
+	case bpTagNull:
+		switch tag & 0x0F {
+		case bpTagNull:
+			o.kind = NullKind
+		case bpTagBoolTrue, bpTagBoolFalse:
+			o.kind = BooleanKind
+		case bpTagURL, bpTagURLWithBase:
+			o.kind = URLKind
+		case bpTagUUID:
+			o.kind = UUIDKind
+		}
+	case bpTagInteger:
+		o.kind = IntegerKind
+	case bpTagReal:
+		o.kind = RealKind
+	case bpTagDate:
+		o.kind = DateKind
+	case bpTagData:
+		o.kind = DataKind
+	case bpTagASCIIString, bpTagUTF16String:
+		o.kind = StringKind
+	case bpTagUID:
+		o.kind = UIDKind
+	case bpTagArray, bpTagOrderedSet, bpTagSet, bpTagDictionary:
+		o.count, o.refs = p.countForTagAtOffset(off)
+		refs := o.count
+		switch tag & 0xF0 {
+		case bpTagArray:
+			o.kind = ArrayKind
+		case bpTagOrderedSet:
+			o.kind = OrderedSetKind
+		case bpTagSet:
+			o.kind = SetKind
+		case bpTagDictionary:
+			// a dictionary is an object list of [key key key val val val]
+			o.kind = DictionaryKind
+			refs *= 2
+		}
+		p.limits.checkLength(o.count)
+		if uint64(o.refs)+refs*uint64(p.trailer.ObjectRefSize) > p.trailer.OffsetTableOffset {
+			panic(fmt.Errorf("list@%#x length (%v) puts its end beyond the offset table at %#x", o.refs, refs, p.trailer.OffsetTableOffset))
+		}
+	}
+	if o.kind == InvalidKind {
+		panic(fmt.Errorf("unexpected atom %#02x at offset %#x", tag, off))
+	}
+	return o
+}
+
+// A BinaryObject is an object in a document read by a BinaryReader.
+// Only its kind and, for an array, set or dictionary, its length have been read;
+// everything else is read when it is asked for.
+//
+// The zero BinaryObject is invalid.
+type BinaryObject struct {
+	r     *BinaryReader
+	id    uint64
+	kind  Kind
+	count uint64 // number of array or set elements, or dictionary entries
+	refs  offset // start of the list of object references of an array, set or dictionary
+}
+
+// Kind returns the kind of property list value o holds.
+func (o BinaryObject) Kind() Kind {
+	return o.kind
+}
+
+// Len returns the number of elements in an array or set, or entries in a dictionary.
+// It returns 0 for any other kind of object.
+func (o BinaryObject) Len() int {
+	return int(o.count)
+}
+
+// ref returns the object ID at position i in o's reference list.
+func (o BinaryObject) ref(i uint64) (id uint64, err error) {
+	err = o.r.parse(func(p *bplistParser) {
+		id, _ = p.parseObjectRefAtOffset(o.refs + offset(i*uint64(p.trailer.ObjectRefSize)))
+	})
+	return
+}
+
+// Index returns the i'th element of an array or set. It panics if o is not an array or set, or i is out of range.
+func (o BinaryObject) Index(i int) (BinaryObject, error) {
+	if !o.kind.isArray() {
+		panic(fmt.Sprintf("plist: Index of %v BinaryObject", o.kind))
+	}
+	if i < 0 || uint64(i) >= o.count {
+		panic(fmt.Sprintf("plist: BinaryObject index %d out of range [0:%d]", i, o.count))
+	}
+	id, err := o.ref(uint64(i))
+	if err != nil {
+		return BinaryObject{}, err
+	}
+	return o.r.object(id)
+}
+
+// Entry returns the key and value of the i'th entry of a dictionary, in document order.
+// It panics if o is not a dictionary or i is out of range.
+func (o BinaryObject) Entry(i int) (string, BinaryObject, error) {
+	if o.kind != DictionaryKind {
+		panic(fmt.Sprintf("plist: Entry of %v BinaryObject", o.kind))
+	}
+	if i < 0 || uint64(i) >= o.count {
+		panic(fmt.Sprintf("plist: BinaryObject entry %d out of range [0:%d]", i, o.count))
+	}
+	key, err := o.key(uint64(i))
+	if err != nil {
+		return "", BinaryObject{}, err
+	}
+	id, err := o.ref(o.count + uint64(i))
+	if err != nil {
+		return "", BinaryObject{}, err
+	}
+	val, err := o.r.object(id)
+	return key, val, err
+}
+
+// key reads the i'th key of a dictionary.
+func (o BinaryObject) key(i uint64) (key string, err error) {
+	err = o.r.parse(func(p *bplistParser) {
+		id, _ := p.parseObjectRefAtOffset(o.refs + offset(i*uint64(p.trailer.ObjectRefSize)))
+		str, ok := p.objectAtIndex(id).(cfString)
+		if !ok {
+			panic(fmt.Errorf("dictionary object#%d contains non-string key at index %d", o.id, i))
+		}
+		key = string(str)
+	})
+	return
+}
+
+// Keys returns the keys of a dictionary, in document order. It panics if o is not a dictionary.
+func (o BinaryObject) Keys() ([]string, error) {
+	if o.kind != DictionaryKind {
+		panic(fmt.Sprintf("plist: Keys of %v BinaryObject", o.kind))
+	}
+	keys := make([]string, o.count)
+	for i := range keys {
+		key, err := o.key(uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// Lookup returns the value stored under key in a dictionary, reading keys until it is found.
+// It panics if o is not a dictionary.
+func (o BinaryObject) Lookup(key string) (BinaryObject, bool, error) {
+	if o.kind != DictionaryKind {
+		panic(fmt.Sprintf("plist: Lookup in %v BinaryObject", o.kind))
+	}
+	for i := uint64(0); i < o.count; i++ {
+		k, err := o.key(i)
+		if err != nil {
+			return BinaryObject{}, false, err
+		}
+		if k != key {
+			continue
+		}
+		id, err := o.ref(o.count + i)
+		if err != nil {
+			return BinaryObject{}, false, err
+		}
+		val, err := o.r.object(id)
+		return val, err == nil, err
+	}
+	return BinaryObject{}, false, nil
+}
+
+// Get returns the object addressed by path, relative to o; see Path.Get.
+func (o BinaryObject) Get(path Path) (BinaryObject, error) {
+	for i, seg := range path {
+		var err error
+		switch o.kind {
+		case DictionaryKind:
+			var ok bool
+			o, ok, err = o.Lookup(seg)
+			if err == nil && !ok {
+				return BinaryObject{}, path.error("get", i, ErrKeyNotFound)
+			}
+		case ArrayKind, SetKind, OrderedSetKind:
+			index, perr := parseIndex(seg)
+			if perr != nil {
+				return BinaryObject{}, path.error("get", i, perr)
+			}
+			if index >= o.Len() {
+				return BinaryObject{}, path.error("get", i, ErrIndexOutOfRange)
+			}
+			o, err = o.Index(index)
+		default:
+			return BinaryObject{}, path.error("get", i, fmt.Errorf("%w (found %v)", ErrNotContainer, o.kind))
+		}
+		if err != nil {
+			return BinaryObject{}, err
+		}
+	}
+	return o, nil
+}
+
+// Value reads o, and everything within it, into a Value.
+func (o BinaryObject) Value() (Value, error) {
+	var v Value
+	err := o.Unmarshal(&v)
+	return v, err
+}
+
+// Unmarshal reads o, and everything within it, and decodes it into v following the same rules as a Decoder.
+func (o BinaryObject) Unmarshal(v any) error {
+	if o.kind == InvalidKind {
+		return errors.New("plist: Unmarshal of invalid BinaryObject")
+	}
+	var pval cfValue
+	err := o.r.parse(func(p *bplistParser) {
+		pval = p.objectAtIndex(o.id)
+	})
+	if err != nil {
+		return err
+	}
+	d := &Decoder{Format: BinaryFormat, options: o.r.options}
+	return d.unmarshalDocument(pval, reflect.ValueOf(v))
+}
@@ -0,0 +1,207 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// countingReaderAt records how many bytes are read through it.
+type countingReaderAt struct {
+	r    *bytes.Reader
+	read int
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(b, off)
+	c.read += n
+	return n, err
+}
+
+func TestBinaryReader(t *testing.T) {
+	type track struct {
+		Name    string `plist:"Name"`
+		TrackID int    `plist:"Track ID"`
+	}
+	tracks := make(map[string]track)
+	var playlist []int
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprint(i)
+		tracks[id] = track{Name: "Track " + id, TrackID: i}
+		playlist = append(playlist, i)
+	}
+	library := map[string]any{
+		"Major Version": 1,
+		"Tracks":        tracks,
+		"Playlists":     []any{map[string]any{"Name": "All", "Items": playlist}},
+	}
+	doc, err := Marshal(library, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := &countingReaderAt{r: bytes.NewReader(doc)}
+	reader, err := NewBinaryReader(source, int64(len(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := reader.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Kind() != DictionaryKind || root.Len() != 3 {
+		t.Fatalf("expected a dictionary with 3 entries, got %v with %d", root.Kind(), root.Len())
+	}
+
+	subtest(t, "Lookup", func(t *testing.T) {
+		source.read = 0
+		obj, err := root.Get(Path{"Tracks", "1234"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tr track
+		if err := obj.Unmarshal(&tr); err != nil {
+			t.Fatal(err)
+		}
+		if tr != (track{"Track 1234", 1234}) {
+			t.Errorf("unexpected track %#v", tr)
+		}
+		if source.read > len(doc)/2 {
+			t.Errorf("expected a single lookup to read a fraction of the document, read %d of %d bytes", source.read, len(doc))
+		}
+	})
+
+	subtest(t, "Iterate", func(t *testing.T) {
+		items, err := root.Get(MustParsePath("Playlists[0].Items"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if items.Kind() != ArrayKind || items.Len() != len(playlist) {
+			t.Fatalf("expected an array of %d items, got %v with %d", len(playlist), items.Kind(), items.Len())
+		}
+		for i := 0; i < items.Len(); i += 250 {
+			item, err := items.Index(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := item.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, _ := v.AsInt(); n != int64(i) {
+				t.Errorf("item %d: expected %d, got %v", i, i, v.Interface())
+			}
+		}
+	})
+
+	subtest(t, "Entries", func(t *testing.T) {
+		keys, err := root.Keys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(keys, []string{"Major Version", "Playlists", "Tracks"}) {
+			t.Errorf("unexpected keys %v", keys)
+		}
+		key, val, err := root.Entry(0)
+		if err != nil || key != "Major Version" || val.Kind() != IntegerKind {
+			t.Errorf("unexpected first entry %q (%v): %v", key, val.Kind(), err)
+		}
+		if _, ok, err := root.Lookup("Missing"); ok || err != nil {
+			t.Errorf("expected Missing to be absent, got %v (%v)", ok, err)
+		}
+		if _, err := root.Get(Path{"Tracks", "9999"}); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("expected ErrKeyNotFound, got %v", err)
+		}
+	})
+
+	subtest(t, "Whole Document", func(t *testing.T) {
+		var lazy, eager map[string]any
+		if err := root.Unmarshal(&lazy); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Unmarshal(doc, &eager); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lazy, eager) {
+			t.Error("expected the lazily read document to match the decoded one")
+		}
+	})
+}
+
+func TestBinaryReaderMarkers(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a")
+	doc, err := Marshal(map[string]any{
+		"Null":    NewNull(),
+		"URL":     u,
+		"UUID":    markerUUID,
+		"Set":     Set{"a", "b"},
+		"Ordered": OrderedSet{uint64(1), uint64(2)},
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewBinaryReader(bytes.NewReader(doc), int64(len(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := reader.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, kind := range map[string]Kind{"Null": NullKind, "URL": URLKind, "UUID": UUIDKind, "Set": SetKind, "Ordered": OrderedSetKind} {
+		obj, ok, err := root.Lookup(key)
+		if err != nil || !ok {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if obj.Kind() != kind {
+			t.Errorf("%s: expected kind %v, got %v", key, kind, obj.Kind())
+		}
+	}
+
+	second, err := root.Get(Path{"Set", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := second.Value(); err != nil || v.Interface() != "b" {
+		t.Errorf("expected the second member of the set to be b, got %v (%v)", v.Interface(), err)
+	}
+	ordered, _, _ := root.Lookup("Ordered")
+	if v, err := ordered.Value(); err != nil || !v.Equal(NewOrderedSet(NewUint(1), NewUint(2))) || ordered.Len() != 2 {
+		t.Errorf("unexpected ordered set %v (%v)", v.Interface(), err)
+	}
+	uuid, _, _ := root.Lookup("UUID")
+	if v, err := uuid.Value(); err != nil || v.Interface() != markerUUID {
+		t.Errorf("expected UUID %v, got %v (%v)", markerUUID, v.Interface(), err)
+	}
+}
+
+func TestBinaryReaderInvalid(t *testing.T) {
+	for i, data := range InvalidBplists {
+		subtest(t, fmt.Sprint(i), func(t *testing.T) {
+			reader, err := NewBinaryReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return
+			}
+			root, err := reader.Root()
+			if err != nil {
+				return
+			}
+			var val any
+			if err := root.Unmarshal(&val); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	doc, _ := Marshal([]string{"a", "b"}, BinaryFormat)
+	if _, err := NewBinaryReader(bytes.NewReader(doc[:len(doc)-4]), int64(len(doc))); err == nil {
+		t.Error("expected an error for a truncated reader")
+	}
+	if _, err := NewBinaryReaderOptions(bytes.NewReader(doc), int64(len(doc)), DecoderOptions{MaxObjects: 2}); err == nil {
+		t.Error("expected MaxObjects to be enforced")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"io"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -139,3 +140,20 @@ func TestBplistLatin1ToUTF16(t *testing.T) {
 		return
 	}
 }
+
+func TestBplistUnsupportedVersions(t *testing.T) {
+	for _, header := range []string{"bplist15", "bplist16", "bplist17"} {
+		subtest(t, header, func(t *testing.T) {
+			doc := append([]byte(header), make([]byte, 40)...)
+			var v any
+			_, err := Unmarshal(doc, &v)
+			if err == nil || !strings.Contains(err.Error(), "unsupported version") {
+				t.Errorf("expected an unsupported version error, got %v", err)
+			}
+		})
+	}
+
+	if _, err := Marshal("x", 5); err == nil {
+		t.Error("expected encoding an unknown format to fail")
+	}
+}
@@ -0,0 +1,378 @@
+package plist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// A BinaryWriter writes a binary property list a value at a time, without holding the document in memory.
+//
+// Values are written depth-first: an array is opened with BeginArray, its elements are written
+// in order, and it is closed with EndArray; a dictionary is written the same way, with a call to
+// Key before each of its values. The document holds exactly one top-level value, and Close
+// finishes it by writing the offset table and trailer.
+//
+// Every object is written out as soon as it is complete. The only things a BinaryWriter keeps
+// are the offset of each object written (8 bytes apiece) and the object references of the
+// arrays and dictionaries that are still open. Unlike an Encoder, it does not look for
+// repeated values to share, so every value, including every dictionary key, is a separate object.
+//
+// Object references in a binary property list have a fixed size that must be chosen before the
+// first array or dictionary is finished. NewBinaryWriter takes an upper bound on the number of
+// objects, and WriteBinary counts them in a first pass.
+//
+// Once a method returns an error, every later call returns the same error. Output is buffered,
+// so an error from the underlying writer is returned by the first call to fill the buffer after it.
+type BinaryWriter struct {
+	gen        *bplistGenerator
+	buf        *bufio.Writer
+	out        *recordingWriter
+	maxObjects uint64
+	counting   bool // only count objects; see WriteBinary
+
+	objects  uint64
+	offsets  []uint64
+	open     []binaryContainer
+	top      uint64
+	complete bool // whether the top-level value has been written
+	err      error
+}
+
+// recordingWriter records the first error returned by the writer underneath a BinaryWriter.
+type recordingWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (r *recordingWriter) Write(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.w.Write(b)
+	r.err = err
+	return n, err
+}
+
+// binaryContainer holds the object references of an array or dictionary that has not been ended.
+type binaryContainer struct {
+	dict bool
+	set  cfSetKind // for values written by WriteValue
+	keys []uint64
+	refs []uint64
+}
+
+// NewBinaryWriter returns a BinaryWriter that writes to w. maxObjects is an upper bound
+// on the number of objects the document will hold: it sets the size of object references,
+// and writing more objects than it allows is an error.
+func NewBinaryWriter(w io.Writer, maxObjects uint64) *BinaryWriter {
+	out := &recordingWriter{w: w}
+	buf := bufio.NewWriter(out)
+	gen := newBplistGenerator(buf)
+	gen.trailer.ObjectRefSize = uint8(bplistMinimumIntSize(maxObjects))
+	return &BinaryWriter{gen: gen, buf: buf, out: out, maxObjects: maxObjects}
+}
+
+// WriteBinary writes the binary property list produced by emit to w, using the smallest
+// object references that will do. emit is called twice, with a different BinaryWriter each
+// time: first to count the document's objects, with nothing written, and then to write it.
+// It must make the same calls both times. WriteBinary closes the BinaryWriter itself.
+func WriteBinary(w io.Writer, emit func(bw *BinaryWriter) error) error {
+	counter := NewBinaryWriter(io.Discard, math.MaxUint64)
+	counter.counting = true
+	if err := emit(counter); err != nil {
+		return err
+	}
+	if err := counter.Close(); err != nil {
+		return err
+	}
+
+	bw := NewBinaryWriter(w, counter.objects)
+	if err := emit(bw); err != nil {
+		return err
+	}
+	if err := bw.Close(); err != nil {
+		return err
+	}
+	if bw.objects != counter.objects {
+		return fmt.Errorf("plist: WriteBinary counted %d objects, but %d were written", counter.objects, bw.objects)
+	}
+	return nil
+}
+
+// do runs f, turning a panic into the writer's sticky error.
+func (w *BinaryWriter) do(f func()) (err error) {
+	if w.err != nil {
+		return w.err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			w.err = r.(error)
+			err = w.err
+		}
+	}()
+	f()
+	return nil
+}
+
+// checkWriter stops the document at the first error from the underlying writer,
+// rather than building the rest of it only to fail in Close.
+func (w *BinaryWriter) checkWriter() {
+	if w.out.err != nil {
+		panic(w.out.err)
+	}
+}
+
+// checkValue checks that a value can be written: the document must not have its
+// top-level value yet, and a dictionary value must follow its key.
+func (w *BinaryWriter) checkValue() {
+	if w.complete {
+		panic(errors.New("plist: BinaryWriter already wrote its top-level value"))
+	}
+	if n := len(w.open); n > 0 && w.open[n-1].dict && len(w.open[n-1].keys) == len(w.open[n-1].refs) {
+		panic(errors.New("plist: BinaryWriter needs a key before each dictionary value"))
+	}
+}
+
+// beginObject records the offset of the next object.
+func (w *BinaryWriter) beginObject() {
+	if w.objects >= w.maxObjects {
+		panic(fmt.Errorf("plist: BinaryWriter wrote more than %d objects", w.maxObjects))
+	}
+
+	if w.objects == 0 {
+		w.gen.writer.Write([]byte("bplist00"))
+	}
+	if !w.counting {
+		w.offsets = append(w.offsets, uint64(w.gen.writer.BytesWritten()))
+	}
+	w.objects++
+}
+
+// endObject places the object just written in its container, or makes it the top-level value.
+func (w *BinaryWriter) endObject() {
+	w.checkWriter()
+	id := w.objects - 1
+	if n := len(w.open); n > 0 {
+		w.open[n-1].refs = append(w.open[n-1].refs, id)
+		return
+	}
+	w.top = id
+	w.complete = true
+}
+
+func (w *BinaryWriter) scalar(write func()) error {
+	return w.do(func() {
+		w.checkValue()
+		w.beginObject()
+		write()
+		w.endObject()
+	})
+}
+
+// WriteString writes a string.
+func (w *BinaryWriter) WriteString(s string) error {
+	return w.scalar(func() { w.gen.writeStringTag(s) })
+}
+
+// WriteInt writes a signed integer.
+func (w *BinaryWriter) WriteInt(n int64) error {
+	return w.scalar(func() { w.gen.writeIntTag(true, uint64(n)) })
+}
+
+// WriteUint writes an unsigned integer.
+func (w *BinaryWriter) WriteUint(n uint64) error {
+	return w.scalar(func() { w.gen.writeIntTag(false, n) })
+}
+
+// WriteInt128 writes a 128-bit integer. Like an Encoder, it uses 64 bits if the integer fits in them.
+func (w *BinaryWriter) WriteInt128(i Int128) error {
+	return w.scalar(func() { w.gen.writePlistValue(newInt128Number(i)) })
+}
+
+// WriteReal writes a 64-bit real.
+func (w *BinaryWriter) WriteReal(f float64) error {
+	return w.scalar(func() { w.gen.writeRealTag(f, 64) })
+}
+
+// WriteReal32 writes a 32-bit real.
+func (w *BinaryWriter) WriteReal32(f float32) error {
+	return w.scalar(func() { w.gen.writeRealTag(float64(f), 32) })
+}
+
+// WriteBool writes a boolean.
+func (w *BinaryWriter) WriteBool(b bool) error {
+	return w.scalar(func() { w.gen.writeBoolTag(b) })
+}
+
+// WriteData writes data.
+func (w *BinaryWriter) WriteData(b []byte) error {
+	return w.scalar(func() { w.gen.writeDataTag(b) })
+}
+
+// WriteDate writes a date.
+func (w *BinaryWriter) WriteDate(t time.Time) error {
+	return w.scalar(func() { w.gen.writeDateTag(t) })
+}
+
+// WriteUID writes a UID.
+func (w *BinaryWriter) WriteUID(u UID) error {
+	return w.scalar(func() { w.gen.writeUIDTag(u) })
+}
+
+// WriteValue marshals v as an Encoder with PreserveKeyOrder would, and writes the result:
+// dictionaries keep the order their keys were given in (the fields of a struct in the order
+// they are declared, and an OrderedDictionary or Value in its own order), and map keys are sorted.
+func (w *BinaryWriter) WriteValue(v any) error {
+	return w.do(func() {
+		pval := (&Encoder{preserveKeyOrder: true}).marshal(reflect.ValueOf(v))
+		if pval == nil {
+			panic(errors.New("plist: no value to write"))
+		}
+		w.writePlistValue(pval)
+	})
+}
+
+func (w *BinaryWriter) writePlistValue(pval cfValue) {
+	switch pval := pval.(type) {
+	case *cfArray:
+		w.begin(false)
+		w.open[len(w.open)-1].set = pval.set
+		for _, subv := range pval.values {
+			w.writePlistValue(subv)
+		}
+		w.end(false)
+	case *cfDictionary:
+		w.begin(true)
+		for i, k := range pval.keys {
+			w.key(k)
+			w.writePlistValue(pval.values[i])
+		}
+		w.end(true)
+	default:
+		w.checkValue()
+		w.beginObject()
+		w.gen.writePlistValue(pval)
+		w.endObject()
+	}
+}
+
+// BeginArray starts an array; its elements are the values written until the matching EndArray.
+func (w *BinaryWriter) BeginArray() error {
+	return w.do(func() { w.begin(false) })
+}
+
+// EndArray finishes the array started by the matching BeginArray.
+func (w *BinaryWriter) EndArray() error {
+	return w.do(func() { w.end(false) })
+}
+
+// BeginDictionary starts a dictionary; each of its entries is written as a call
+// to Key followed by a value, until the matching EndDictionary.
+func (w *BinaryWriter) BeginDictionary() error {
+	return w.do(func() { w.begin(true) })
+}
+
+// Key writes the key of the next entry in the current dictionary.
+func (w *BinaryWriter) Key(k string) error {
+	return w.do(func() { w.key(k) })
+}
+
+// EndDictionary finishes the dictionary started by the matching BeginDictionary.
+func (w *BinaryWriter) EndDictionary() error {
+	return w.do(func() { w.end(true) })
+}
+
+func (w *BinaryWriter) begin(dict bool) {
+	w.checkValue()
+	w.open = append(w.open, binaryContainer{dict: dict})
+}
+
+func (w *BinaryWriter) key(k string) {
+	n := len(w.open)
+	if n == 0 || !w.open[n-1].dict {
+		panic(errors.New("plist: BinaryWriter can only write a key in a dictionary"))
+	}
+	c := &w.open[n-1]
+	if len(c.keys) != len(c.refs) {
+		panic(errors.New("plist: BinaryWriter needs a value for the previous key"))
+	}
+
+	// The key is written as an object of its own; it is recorded as a key rather than a value.
+	w.beginObject()
+	w.gen.writeStringTag(k)
+	w.checkWriter()
+	c.keys = append(c.keys, w.objects-1)
+}
+
+func (w *BinaryWriter) end(dict bool) {
+	n := len(w.open)
+	if n == 0 || w.open[n-1].dict != dict {
+		kind := "array"
+		if dict {
+			kind = "dictionary"
+		}
+		panic(fmt.Errorf("plist: BinaryWriter has no %s to end", kind))
+	}
+	c := w.open[n-1]
+	if dict && len(c.keys) != len(c.refs) {
+		panic(errors.New("plist: BinaryWriter needs a value for the last key"))
+	}
+	w.open = w.open[:n-1]
+
+	w.beginObject()
+	if dict {
+		w.gen.writeCountedTag(bpTagDictionary, uint64(len(c.refs)))
+		w.writeRefs(c.keys)
+	} else {
+		w.gen.writeCountedTag(bplistArrayTag(c.set), uint64(len(c.refs)))
+	}
+	w.writeRefs(c.refs)
+	w.endObject()
+}
+
+func (w *BinaryWriter) writeRefs(refs []uint64) {
+	for _, ref := range refs {
+		w.gen.writeSizedInt(ref, int(w.gen.trailer.ObjectRefSize))
+	}
+}
+
+// Close finishes the document by writing its offset table and trailer, and flushes it
+// to the underlying writer. It does not close the underlying writer.
+func (w *BinaryWriter) Close() error {
+	err := w.do(func() {
+		if len(w.open) > 0 {
+			panic(fmt.Errorf("plist: BinaryWriter closed with %d arrays or dictionaries still open", len(w.open)))
+		}
+		if !w.complete {
+			panic(errors.New("plist: BinaryWriter closed without a top-level value"))
+		}
+
+		p := w.gen
+		p.trailer.NumObjects = w.objects
+		p.trailer.TopObject = w.top
+		p.trailer.OffsetIntSize = uint8(bplistMinimumIntSize(uint64(p.writer.BytesWritten())))
+		p.trailer.OffsetTableOffset = uint64(p.writer.BytesWritten())
+		for _, off := range w.offsets {
+			p.writeSizedInt(off, int(p.trailer.OffsetIntSize))
+		}
+		binary.Write(p.writer, binary.BigEndian, p.trailer)
+
+		if err := w.buf.Flush(); err != nil {
+			panic(err)
+		}
+	})
+	if err == nil {
+		w.err = errors.New("plist: BinaryWriter is closed")
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeLibrary(bw *BinaryWriter) error {
+	bw.BeginDictionary()
+	bw.Key("Date")
+	bw.WriteDate(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	bw.Key("Tracks")
+	bw.BeginArray()
+	for i := 0; i < 300; i++ {
+		bw.WriteValue(map[string]any{"ID": i, "Name": "Track"})
+	}
+	bw.EndArray()
+	bw.Key("Flags")
+	bw.BeginArray()
+	bw.WriteBool(true)
+	bw.WriteInt(-1)
+	bw.WriteUint(1 << 63)
+	bw.WriteReal(1.5)
+	bw.WriteReal32(0.25)
+	bw.WriteData([]byte{1, 2})
+	bw.WriteUID(7)
+	bw.WriteString("ü")
+	bw.EndArray()
+	return bw.EndDictionary()
+}
+
+func TestBinaryWriter(t *testing.T) {
+	var expected map[string]any
+	{
+		tracks := make([]any, 300)
+		for i := range tracks {
+			tracks[i] = map[string]any{"ID": uint64(i), "Name": "Track"}
+		}
+		expected = map[string]any{
+			"Date":   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			"Tracks": tracks,
+			"Flags":  []any{true, int64(-1), uint64(1 << 63), 1.5, float32(0.25), []byte{1, 2}, UID(7), "ü"},
+		}
+	}
+
+	check := func(t *testing.T, doc []byte, refSize uint8) {
+		t.Helper()
+		var got map[string]any
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %#v, got %#v", expected, got)
+		}
+		if size := doc[len(doc)-32+7]; size != refSize {
+			t.Errorf("expected %d-byte object references, got %d", refSize, size)
+		}
+	}
+
+	subtest(t, "Declared", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := NewBinaryWriter(&buf, 1<<20)
+		if err := writeLibrary(bw); err != nil {
+			t.Fatal(err)
+		}
+		if err := bw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		check(t, buf.Bytes(), 4)
+	})
+
+	subtest(t, "Two Pass", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteBinary(&buf, writeLibrary); err != nil {
+			t.Fatal(err)
+		}
+		check(t, buf.Bytes(), 2)
+	})
+
+	subtest(t, "Too Many Objects", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := NewBinaryWriter(&buf, 100)
+		if err := writeLibrary(bw); err == nil {
+			t.Error("expected an error")
+		}
+		if err := bw.Close(); err == nil {
+			t.Error("expected the error to stick")
+		}
+	})
+}
+
+func TestBinaryWriterKeepsKeyOrder(t *testing.T) {
+	var ordered Value
+	if _, err := Unmarshal([]byte("{zebra = 1; apple = 2;}"), &ordered); err != nil {
+		t.Fatal(err)
+	}
+	type record struct {
+		Name string
+		Age  int
+	}
+
+	for _, test := range []struct {
+		Name  string
+		Value any
+		Keys  []string
+	}{
+		{"OrderedDictionary", OrderedDictionary{{"b", 1}, {"a", 2}}, []string{"b", "a"}},
+		{"Value", ordered, []string{"zebra", "apple"}},
+		{"Struct", record{"x", 1}, []string{"Name", "Age"}},
+		{"Map", map[string]int{"b": 1, "a": 2}, []string{"a", "b"}},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteBinary(&buf, func(bw *BinaryWriter) error { return bw.WriteValue(test.Value) }); err != nil {
+				t.Fatal(err)
+			}
+			var got OrderedDictionary
+			if _, err := Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if keys := got.Keys(); !reflect.DeepEqual(keys, test.Keys) {
+				t.Errorf("expected keys %v, got %v", test.Keys, keys)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write, counting the attempts.
+type failingWriter struct {
+	writes int
+}
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	f.writes++
+	return 0, errFailingWriter
+}
+
+var errFailingWriter = errors.New("disk full")
+
+func TestBinaryWriterStopsAtWriteError(t *testing.T) {
+	out := &failingWriter{}
+	bw := NewBinaryWriter(out, 1<<20)
+	bw.BeginArray()
+	written := 0
+	for ; written < 100000; written++ {
+		if err := bw.WriteString("a string long enough to fill the buffer soon"); err != nil {
+			if !errors.Is(err, errFailingWriter) {
+				t.Errorf("expected the write error, got %v", err)
+			}
+			break
+		}
+	}
+	if written == 100000 {
+		t.Fatal("expected the write error to stop the document")
+	}
+	if err := bw.EndArray(); !errors.Is(err, errFailingWriter) {
+		t.Errorf("expected the write error to stick, got %v", err)
+	}
+	if err := bw.Close(); !errors.Is(err, errFailingWriter) {
+		t.Errorf("expected Close to report the write error, got %v", err)
+	}
+	if out.writes != 1 {
+		t.Errorf("expected one write to the failed writer, got %d", out.writes)
+	}
+}
+
+func TestBinaryWriterMisuse(t *testing.T) {
+	misuseTests := []struct {
+		Name string
+		Emit func(bw *BinaryWriter) error
+	}{
+		{"Value Without Key", func(bw *BinaryWriter) error {
+			bw.BeginDictionary()
+			return bw.WriteInt(1)
+		}},
+		{"Key Outside Dictionary", func(bw *BinaryWriter) error {
+			bw.BeginArray()
+			return bw.Key("a")
+		}},
+		{"Key Without Value", func(bw *BinaryWriter) error {
+			bw.BeginDictionary()
+			bw.Key("a")
+			return bw.EndDictionary()
+		}},
+		{"Mismatched End", func(bw *BinaryWriter) error {
+			bw.BeginArray()
+			return bw.EndDictionary()
+		}},
+		{"Two Top-Level Values", func(bw *BinaryWriter) error {
+			bw.WriteString("a")
+			return bw.WriteString("b")
+		}},
+		{"Unclosed Array", func(bw *BinaryWriter) error {
+			bw.BeginArray()
+			return bw.Close()
+		}},
+		{"Empty Document", func(bw *BinaryWriter) error {
+			return bw.Close()
+		}},
+	}
+
+	for _, mt := range misuseTests {
+		subtest(t, mt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := mt.Emit(NewBinaryWriter(&buf, 16)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -523,7 +523,7 @@ var tests = []TestData{
 		Documents: map[int][]byte{
 			OpenStepFormat: []byte(`+Inf`),
 			GNUStepFormat:  []byte(`<*R+Inf>`),
-			XMLFormat:      []byte(xmlPreamble + `<plist version="1.0"><real>inf</real></plist>`),
+			XMLFormat:      []byte(xmlPreamble + `<plist version="1.0"><real>+infinity</real></plist>`),
 			BinaryFormat:   []byte{98, 112, 108, 105, 115, 116, 48, 48, 35, 127, 240, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 17},
 		},
 	},
@@ -533,7 +533,7 @@ var tests = []TestData{
 		Documents: map[int][]byte{
 			OpenStepFormat: []byte(`-Inf`),
 			GNUStepFormat:  []byte(`<*R-Inf>`),
-			XMLFormat:      []byte(xmlPreamble + `<plist version="1.0"><real>-inf</real></plist>`),
+			XMLFormat:      []byte(xmlPreamble + `<plist version="1.0"><real>-infinity</real></plist>`),
 			BinaryFormat:   []byte{98, 112, 108, 105, 115, 116, 48, 48, 35, 255, 240, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 17},
 		},
 	},
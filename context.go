@@ -0,0 +1,60 @@
+package plist
+
+import (
+	"context"
+	"reflect"
+)
+
+// cancelCheckInterval is the number of steps taken between checks of a context.
+const cancelCheckInterval = 256
+
+// cancellation lets long-running loops poll a context without paying for
+// a call to ctx.Err() on every iteration. The zero value never cancels.
+type cancellation struct {
+	ctx   context.Context
+	ticks int
+}
+
+// tick counts one step of work, and panics with the context's error once it has been cancelled.
+func (c *cancellation) tick() {
+	if c.ctx == nil {
+		return
+	}
+	c.ticks++
+	if c.ticks%cancelCheckInterval != 0 {
+		return
+	}
+	if err := c.ctx.Err(); err != nil {
+		panic(err)
+	}
+}
+
+// DecodeContext works like Decode, but gives up and returns ctx.Err() shortly after ctx is cancelled.
+func (p *Decoder) DecodeContext(ctx context.Context, v any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.cancel = cancellation{ctx: ctx}
+	defer func() { p.cancel = cancellation{} }()
+
+	err := p.DecodeForReflect(reflect.ValueOf(v))
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// EncodeContext works like Encode, but gives up and returns ctx.Err() shortly after ctx is cancelled.
+func (p *Encoder) EncodeContext(ctx context.Context, v any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.cancel = cancellation{ctx: ctx}
+	defer func() { p.cancel = cancellation{} }()
+
+	err := p.Encode(v)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package plist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countdownContext reports itself cancelled after Err has been polled a set number of times.
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *countdownContext) Err() error {
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return nil
+}
+
+func largeContextValue() any {
+	list := make([]any, 10000)
+	for i := range list {
+		list[i] = map[string]any{"index": i, "name": "item"}
+	}
+	return map[string]any{"items": list}
+}
+
+func TestDecodeContext(t *testing.T) {
+	value := largeContextValue()
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		doc, err := Marshal(value, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			subtest(t, "Not Cancelled", func(t *testing.T) {
+				var val any
+				if err := NewDecoder(bytes.NewReader(doc)).DecodeContext(context.Background(), &val); err != nil {
+					t.Error(err)
+				}
+			})
+
+			subtest(t, "Already Cancelled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				var val any
+				if err := NewDecoder(bytes.NewReader(doc)).DecodeContext(ctx, &val); err != context.Canceled {
+					t.Errorf("expected context.Canceled, got %v", err)
+				}
+			})
+
+			subtest(t, "Cancelled While Parsing", func(t *testing.T) {
+				ctx := &countdownContext{Context: context.Background(), remaining: 2}
+				var val any
+				if err := NewDecoder(bytes.NewReader(doc)).DecodeContext(ctx, &val); err != context.Canceled {
+					t.Errorf("expected context.Canceled, got %v", err)
+				}
+				if val != nil {
+					t.Error("expected no value to be decoded")
+				}
+			})
+		})
+	}
+
+	subtest(t, "Deadline Exceeded", func(t *testing.T) {
+		doc, _ := Marshal(value, BinaryFormat)
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		var val any
+		if err := NewDecoder(bytes.NewReader(doc)).DecodeContext(ctx, &val); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestEncodeContext(t *testing.T) {
+	value := largeContextValue()
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewEncoderForFormat(&buf, format).EncodeContext(context.Background(), value); err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			ctx := &countdownContext{Context: context.Background(), remaining: 2}
+			if err := NewEncoderForFormat(&buf, format).EncodeContext(ctx, value); err != context.Canceled {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("expected nothing to be written, got %d bytes", buf.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package plist
+
+import (
+	"math"
+	"time"
+)
+
+// appleEpoch is CoreFoundation's reference date, 2001-01-01 00:00:00 UTC, in Unix time.
+// Binary property lists and keyed archives store dates as seconds since it.
+const appleEpoch = 978307200
+
+// appleSeconds returns t as a number of seconds since the reference date.
+// Whole and fractional seconds are converted separately, so that no precision
+// is lost to an intermediate count of nanoseconds.
+func appleSeconds(t time.Time) float64 {
+	return float64(t.Unix()-appleEpoch) + float64(t.Nanosecond())/float64(time.Second)
+}
+
+// timeFromAppleSeconds is the inverse of appleSeconds, to the nearest nanosecond.
+// Dates before the reference date are negative, with a fraction that counts backwards.
+func timeFromAppleSeconds(s float64) time.Time {
+	sec := math.Floor(s)
+	nsec := math.Round((s - sec) * float64(time.Second))
+	return time.Unix(int64(sec)+appleEpoch, int64(nsec)).In(time.UTC)
+}
+
+const (
+	// xmlPlistTimeLayout writes fractional seconds only when a date has them.
+	xmlPlistTimeLayout = time.RFC3339Nano
+
+	textPlistTimeLayoutNano = "2006-01-02 15:04:05.999999999 -0700"
+)
+
+// xmlDateLayouts are the forms of XML date accepted, in addition to RFC 3339. Apple's
+// tools write RFC 3339 in UTC, without fractional seconds, but hand-written property lists
+// vary. Fractional seconds are accepted after the seconds of any of them, and a date
+// without a time zone is in UTC.
+var xmlDateLayouts = []string{
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseXMLDate parses the contents of an XML <date>.
+func parseXMLDate(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(time.RFC3339, s, time.UTC)
+	if err == nil {
+		return t.In(time.UTC), nil
+	}
+	for _, layout := range xmlDateLayouts {
+		if t, lerr := time.ParseInLocation(layout, s, time.UTC); lerr == nil {
+			return t.In(time.UTC), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseTextDate parses a date from a text property list, or from a string standing in for one.
+// It accepts the form GNUstep writes, with or without fractional seconds or a time zone,
+// as well as the forms of XML date.
+func parseTextDate(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(textPlistTimeLayout, s, time.UTC)
+	if err == nil {
+		return t.In(time.UTC), nil
+	}
+	if t, lerr := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC); lerr == nil {
+		return t, nil
+	}
+	if t, lerr := parseXMLDate(s); lerr == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,137 @@
+package plist
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppleSeconds(t *testing.T) {
+	for _, test := range []struct {
+		Name    string
+		Time    time.Time
+		Seconds float64
+	}{
+		{"Reference Date", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+		{"Fraction", time.Date(2001, 1, 1, 0, 0, 1, 250000000, time.UTC), 1.25},
+		{"Before Reference Date", time.Date(2000, 12, 31, 23, 59, 59, 750000000, time.UTC), -0.25},
+		{"Before Unix Epoch", time.Date(1969, 7, 20, 20, 17, 40, 500000000, time.UTC), -992490139.5},
+		{"Microseconds", time.Date(2024, 2, 29, 12, 0, 0, 123456000, time.UTC), 730900800.123456},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			if got := appleSeconds(test.Time); got != test.Seconds {
+				t.Errorf("expected %v seconds, got %v", test.Seconds, got)
+			}
+			got := timeFromAppleSeconds(test.Seconds)
+			if d := got.Sub(test.Time); d < -time.Microsecond || d > time.Microsecond {
+				t.Errorf("expected %v, got %v", test.Time, got)
+			}
+			if again := appleSeconds(got); again != test.Seconds {
+				t.Errorf("expected %v seconds to survive a round trip, got %v", test.Seconds, again)
+			}
+		})
+	}
+}
+
+func TestDatePrecision(t *testing.T) {
+	when := time.Date(2020, 1, 6, 10, 40, 5, 123456789, time.UTC)
+	for _, test := range []struct {
+		Format    int
+		Precision time.Duration
+		Expected  string
+	}{
+		{XMLFormat, 0, "<date>2020-01-06T10:40:05.123456789Z</date>"},
+		{XMLFormat, time.Millisecond, "<date>2020-01-06T10:40:05.123Z</date>"},
+		{XMLFormat, time.Second, "<date>2020-01-06T10:40:05Z</date>"},
+		{GNUStepFormat, 0, "<*D2020-01-06 10:40:05.123456789 +0000>"},
+		{GNUStepFormat, time.Second, "<*D2020-01-06 10:40:05 +0000>"},
+		{OpenStepFormat, time.Millisecond, `"2020-01-06 10:40:05.123 +0000"`},
+	} {
+		subtest(t, FormatNames[test.Format]+" "+test.Precision.String(), func(t *testing.T) {
+			var buf strings.Builder
+			enc := NewEncoderForFormat(&buf, test.Format)
+			enc.DatePrecision(test.Precision)
+			if err := enc.Encode(when); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), test.Expected) {
+				t.Fatalf("expected %s in %s", test.Expected, buf.String())
+			}
+
+			var got time.Time
+			if _, err := Unmarshal([]byte(buf.String()), &got); err != nil {
+				t.Fatal(err)
+			}
+			if expected := when.Truncate(test.Precision); !got.Equal(expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+
+	subtest(t, "Binary", func(t *testing.T) {
+		quarter := time.Date(1999, 3, 4, 5, 6, 7, 250000000, time.UTC)
+		doc, err := Marshal(quarter, BinaryFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got time.Time
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(quarter) {
+			t.Errorf("expected %v, got %v", quarter, got)
+		}
+	})
+}
+
+func TestXMLDateVariants(t *testing.T) {
+	expected := time.Date(2020, 1, 6, 10, 40, 5, 0, time.UTC)
+	for _, s := range []string{
+		"2020-01-06T10:40:05Z",
+		"2020-01-06T11:40:05+01:00",
+		"2020-01-06T11:40:05+0100",
+		"2020-01-06T10:40:05",
+		"2020-01-06T10:40:05.000Z",
+	} {
+		subtest(t, s, func(t *testing.T) {
+			var got time.Time
+			if _, err := Unmarshal([]byte("<plist><date>"+s+"</date></plist>"), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(expected) || got.Location() != time.UTC {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+
+	var got time.Time
+	if _, err := Unmarshal([]byte("<plist><date>2020-01-06</date></plist>"), &got); err != nil || !got.Equal(time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a date-only XML date to be midnight UTC, got %v (%v)", got, err)
+	}
+	if _, err := Unmarshal([]byte("<plist><date>yesterday</date></plist>"), &got); err == nil {
+		t.Error("expected an error for an unparseable date")
+	}
+}
+
+func TestArchiverDatePrecision(t *testing.T) {
+	type event struct {
+		When time.Time `plist:"When"`
+	}
+	value := event{When: time.Date(1969, 7, 20, 20, 17, 40, 500000000, time.UTC)}
+	doc, err := (&Archiver{}).Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := &Archiver{}
+	if err := archive.ReadFromData(doc); err != nil {
+		t.Fatal(err)
+	}
+	var got event
+	if err := archive.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.When.Equal(value.When) {
+		t.Errorf("expected %v, got %v", value.When, got.When)
+	}
+}
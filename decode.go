@@ -1,12 +1,17 @@
 package plist
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"io"
 	"reflect"
 	"runtime"
 )
 
+// decoderBufferSize is the size of the lookahead buffer placed in front of a Decoder's reader.
+const decoderBufferSize = 4096
+
 type parser interface {
 	parseDocument() (cfValue, error)
 }
@@ -16,13 +21,91 @@ type Decoder struct {
 	// the format of the most-recently-decoded property list
 	Format int
 
-	reader io.ReadSeeker
+	reader *bufio.Reader
 	lax    bool
+
+	lengthPrefixed      bool
+	documents           int // number of documents decoded from reader
+	options             DecoderOptions
+	disallowUnknownKeys bool
+	orderedDictionaries bool
+	preserveFloat32     bool
+
+	cancel   cancellation
+	ordered  bool                          // whether valueInterface produces OrderedDictionary rather than map[string]any
+	expanded int                           // values created while unmarshaling the current document
+	shared   map[sharedValue]reflect.Value // see DecoderOptions.PreserveSharing
+	path     keyPath                       // location of the value being unmarshaled
+	unknown  []string                      // paths of keys with no matching struct field
+	missing  []string                      // paths of required keys absent from the document
+}
+
+// sharedValue identifies a container that has already been unmarshaled into a value of type typ.
+// typ is nil for values created by valueInterface.
+type sharedValue struct {
+	pval cfValue
+	typ  reflect.Type
+}
+
+// recordingReader keeps a copy of every byte read through it, so that input
+// consumed by a failed XML parse can be replayed to the text parser.
+type recordingReader struct {
+	reader    *bufio.Reader
+	buf       bytes.Buffer
+	recording bool
+	max       int64 // see DecoderOptions.MaxBytes
+}
+
+func (r *recordingReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	if r.recording {
+		r.buf.Write(b[:n])
+		if err == nil {
+			err = r.checkSize()
+		}
+	}
+	return n, err
+}
+
+// ReadByte makes recordingReader an io.ByteReader, which keeps encoding/xml
+// from reading ahead of the current token.
+func (r *recordingReader) ReadByte() (byte, error) {
+	c, err := r.reader.ReadByte()
+	if err == nil && r.recording {
+		r.buf.WriteByte(c)
+		err = r.checkSize()
+	}
+	return c, err
+}
+
+// checkSize stops the XML parser once the recording is larger than MaxBytes:
+// a text property list that large would be rejected anyway.
+func (r *recordingReader) checkSize() error {
+	if r.max > 0 && int64(r.buf.Len()) > r.max {
+		return &LimitError{"MaxBytes", r.max}
+	}
+	return nil
+}
+
+// stopRecording is called by the XML parser once it has seen its first element;
+// from that point on, a failure can no longer send us to the text parser.
+func (r *recordingReader) stopRecording() {
+	r.recording = false
+}
+
+// replay returns a reader over everything recorded followed by the unread input.
+func (r *recordingReader) replay() io.Reader {
+	return io.MultiReader(bytes.NewReader(r.buf.Bytes()), r.reader)
 }
 
 // Decode works like Unmarshal, except it reads the decoder stream to find property list elements.
 //
 // After Decoding, the Decoder's Format field will be set to one of the plist format constants.
+//
+// Decode may be called repeatedly to read a sequence of property lists from the same stream;
+// it returns io.EOF once the stream holds nothing but whitespace after the last document.
+// Binary and text property lists carry no end marker, so unless length-prefixed framing is
+// enabled (see UseLengthPrefix) they consume the remainder of the stream.
 func (p *Decoder) Decode(v any) error {
 	return p.DecodeForReflect(reflect.ValueOf(v))
 }
@@ -40,31 +123,170 @@ func (p *Decoder) DecodeForReflect(refv reflect.Value) (err error) {
 		}
 	}()
 
-	header := make([]byte, 6)
-	p.reader.Read(header)
-	p.reader.Seek(0, 0)
+	pval, err := p.parseNextDocument()
+	if err != nil {
+		return err
+	}
+	return p.unmarshalDocument(pval, refv)
+}
+
+// unmarshalDocument unmarshals a complete parsed document into refv.
+func (p *Decoder) unmarshalDocument(pval cfValue, refv reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+
+	p.expanded = 0
+	p.shared = nil
+	p.path = p.path[:0]
+	p.unknown = nil
+	p.missing = nil
+	p.ordered = p.orderedDictionaries
+	p.unmarshal(pval, refv)
+	if len(p.missing) > 0 {
+		return &MissingKeyError{Paths: p.missing}
+	}
+	if len(p.unknown) > 0 {
+		return &UnknownKeyError{Paths: p.unknown}
+	}
+	return nil
+}
+
+// UseLengthPrefix causes the Decoder to expect every property list in the stream to be
+// preceded by its length in bytes, as a 4-byte big-endian integer.
+func (p *Decoder) UseLengthPrefix() {
+	p.lengthPrefixed = true
+}
 
+// DisallowUnknownKeys causes the Decoder to return an error when a dictionary being
+// decoded into a struct contains a key that does not match any of the struct's fields.
+// Every matching key is still decoded; the error lists all of the unknown keys.
+func (p *Decoder) DisallowUnknownKeys() {
+	p.disallowUnknownKeys = true
+}
+
+// UseOrderedDictionaries causes the Decoder to decode dictionaries into an
+// OrderedDictionary, keeping their keys in document order, wherever it would
+// otherwise produce a map[string]any for an empty interface.
+func (p *Decoder) UseOrderedDictionaries() {
+	p.orderedDictionaries = true
+}
+
+// PreserveFloat32 causes the Decoder to decode a real in an XML or text property list as a
+// 32-bit real when it is written in the fewest digits that identify a 32-bit real, as an
+// Encoder with PreserveFloat32 writes them. Without it, every such real is 64-bit, as the
+// formats do not say. Binary property lists always keep 32-bit reals.
+func (p *Decoder) PreserveFloat32() {
+	p.preserveFloat32 = true
+}
+
+// SetOptions configures the resource limits enforced while parsing subsequent documents.
+func (p *Decoder) SetOptions(opts DecoderOptions) {
+	p.options = opts
+}
+
+// parseNextDocument finds the next document in the stream and parses it.
+func (p *Decoder) parseNextDocument() (cfValue, error) {
+	reader := p.reader
+	if p.lengthPrefixed {
+		frame, err := p.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		reader = bufio.NewReader(bytes.NewReader(frame))
+	} else if p.documents > 0 {
+		// An empty stream is a valid (empty) OpenStep document, so we can only
+		// report the end of the stream once we've returned at least one document.
+		if err := p.skipWhitespace(); err != nil {
+			return nil, err
+		}
+	}
+
+	pval, err := p.parseDocument(reader)
+	if err != nil {
+		return nil, err
+	}
+	p.documents++
+	return pval, nil
+}
+
+// readFrame reads one length-prefixed document from the stream.
+func (p *Decoder) readFrame() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(p.reader, prefix[:]); err != nil {
+		// io.ReadFull only returns io.EOF if it read nothing at all.
+		return nil, err
+	}
+
+	n := int64(binary.BigEndian.Uint32(prefix[:]))
+	if max := p.options.MaxBytes; max > 0 && n > max {
+		return nil, &LimitError{"MaxBytes", max}
+	}
+
+	// The prefix is not to be trusted, so the frame grows only as its contents arrive.
+	var frame bytes.Buffer
+	if _, err := io.CopyN(&frame, p.reader, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame.Bytes(), nil
+}
+
+// skipWhitespace discards the whitespace separating two documents.
+// It returns io.EOF if there is nothing else in the stream.
+func (p *Decoder) skipWhitespace() error {
+	for {
+		c, err := p.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if !whitespace.ContainsByte(c) {
+			return p.reader.UnreadByte()
+		}
+	}
+}
+
+// parseDocument detects the format of the document at the head of r and parses it.
+func (p *Decoder) parseDocument(r *bufio.Reader) (pval cfValue, err error) {
+	// Peeking does not consume anything, so every parser starts at the top of the document.
+	header, _ := r.Peek(8)
+
+	limits := newLimitTracker(p.options, p.cancel)
 	var parser parser
-	var pval cfValue
-	if bytes.Equal(header, []byte("bplist")) {
-		parser = newBplistParser(p.reader)
+	if bytes.HasPrefix(header, []byte("bplist")) {
+		bp := newBplistParser(r)
+		bp.limits = limits
+		parser = bp
 		pval, err = parser.parseDocument()
 		if err != nil {
 			// Had a bplist header, but still got an error: we have to die here.
-			return err
+			return nil, err
 		}
 		p.Format = BinaryFormat
 	} else {
-		parser = newXMLPlistParser(p.reader)
+		recorder := &recordingReader{reader: r, recording: true, max: p.options.MaxBytes}
+		xp := newXMLPlistParser(recorder)
+		xp.limits = limits
+		xp.preserveFloat32 = p.preserveFloat32
+		parser = xp
 		pval, err = parser.parseDocument()
 		if _, ok := err.(invalidPlistError); ok {
-			// Rewind: the XML parser might have exhausted the file.
-			p.reader.Seek(0, 0)
+			// The XML parser might have consumed the whole stream; replay what it read.
 			// We don't use parser here because we want the textPlistParser type
-			tp := newTextPlistParser(p.reader)
+			tp := newTextPlistParser(recorder.replay())
+			limits.reset()
+			tp.limits = limits
+			tp.preserveFloat32 = p.preserveFloat32
 			pval, err = tp.parseDocument()
 			if err != nil {
-				return err
+				return nil, err
 			}
 			p.Format = tp.format
 			if p.Format == OpenStepFormat {
@@ -74,20 +296,25 @@ func (p *Decoder) DecodeForReflect(refv reflect.Value) (err error) {
 			}
 		} else {
 			if err != nil {
-				return err
+				return nil, err
 			}
 			p.Format = XMLFormat
 		}
 	}
-
-	p.unmarshal(pval, refv)
-	return
+	return pval, nil
 }
 
 // NewDecoder returns a Decoder that reads property list elements from a stream reader, r.
-// NewDecoder requires a Seekable stream for the purposes of file type detection.
+// NewDecoder is kept for compatibility; r does not need to be seekable (see NewStreamDecoder).
 func NewDecoder(r io.ReadSeeker) *Decoder {
-	return &Decoder{Format: InvalidFormat, reader: r, lax: false}
+	return NewStreamDecoder(r)
+}
+
+// NewStreamDecoder returns a Decoder that reads property list elements from r.
+// The stream is never rewound: file type detection peeks at a small lookahead buffer,
+// so r may be a pipe, a socket or an HTTP response body.
+func NewStreamDecoder(r io.Reader) *Decoder {
+	return &Decoder{Format: InvalidFormat, reader: bufio.NewReaderSize(r, decoderBufferSize), lax: false}
 }
 
 // Unmarshal parses a property list document and stores the result in the value pointed to by v.
@@ -100,16 +327,24 @@ func NewDecoder(r io.ReadSeeker) *Decoder {
 // in the interface value. If the interface value is nil, Unmarshal stores one of the following in the interface value:
 //
 //	string, bool, uint64, float64
+//	int64, for negative integers
+//	plist.Int128, for integers that fit in neither int64 nor uint64
 //	plist.UID for "CoreFoundation Keyed Archiver UIDs" (convertible to uint64)
 //	[]byte, for plist data
 //	[]any, for plist arrays
+//	plist.Set and plist.OrderedSet, for the sets of binary property lists
 //	map[string]any, for plist dictionaries
+//	*url.URL and plist.UUID, for the URLs and UUIDs of binary property lists
+//	nil, for the null of binary property lists
 //
 // If a property list value is not appropriate for a given value type, Unmarshal aborts immediately and returns an error.
 //
-// As Go does not support 128-bit types, and we don't want to pretend we're giving the user integer types (as opposed to
-// secretly passing them structs), Unmarshal will drop the high 64 bits of any 128-bit integers encoded in binary property lists.
-// (This is important because CoreFoundation serializes some large 64-bit values as 128-bit values with an empty high half.)
+// Binary property lists can hold integers of up to 128 bits. They can be decoded into plist.Int128, big.Int or
+// *big.Int; decoding an integer into a Go integer type that cannot hold it is an error, rather than a silent truncation.
+//
+// Dictionaries can be decoded into maps whose keys are strings, integers, booleans, encoding.TextUnmarshalers
+// or interfaces that can hold a string, such as any (the keys are then strings);
+// a dictionary key that cannot be parsed as the map's key type is an error.
 //
 // When Unmarshal encounters an OpenStep property list, it will enter a relaxed parsing mode: OpenStep property lists can only store
 // plain old data as strings, so we will attempt to recover integer, floating-point, boolean and date values wherever they are necessary.
@@ -2,9 +2,14 @@ package plist
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func BenchmarkXMLDecode(b *testing.B) {
@@ -202,6 +207,360 @@ func TestFormatDetection(t *testing.T) {
 	}
 }
 
+func TestStreamDecoderFormatDetection(t *testing.T) {
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			for format, doc := range test.Documents {
+				if test.SkipDecode[format] {
+					continue
+				}
+				subtest(t, FormatNames[format], func(t *testing.T) {
+					// OneByteReader hides Seek and forces every parser through short reads.
+					decoder := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(doc)))
+					var val any
+					if err := decoder.Decode(&val); err != nil {
+						t.Fatal(err)
+					}
+
+					want := format
+					if format == GNUStepFormat && decoder.Format == OpenStepFormat {
+						// GNUStep documents without any extended values are indistinguishable from OpenStep.
+						want = OpenStepFormat
+					}
+					if decoder.Format != want {
+						t.Errorf("expected format %s, got %s", FormatNames[want], FormatNames[decoder.Format])
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestDecodeConcatenatedXML(t *testing.T) {
+	stream := xmlPreamble + `<plist version="1.0"><string>first</string></plist>` + "\n" +
+		xmlPreamble + `<plist version="1.0"><array><integer>2</integer></array></plist>` + "\n" +
+		`<plist version="1.0"><dict><key>third</key><true/></dict></plist>` + "\n\n"
+
+	expected := []any{
+		"first",
+		[]any{uint64(2)},
+		map[string]any{"third": true},
+	}
+
+	decoder := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader([]byte(stream))))
+	for i, exp := range expected {
+		var val any
+		if err := decoder.Decode(&val); err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(exp, val) {
+			t.Errorf("document %d: expected %#v, got %#v", i, exp, val)
+		}
+	}
+
+	var val any
+	if err := decoder.Decode(&val); err != io.EOF {
+		t.Errorf("expected io.EOF after the last document, got %v", err)
+	}
+}
+
+func TestDecodeLengthPrefixedDocuments(t *testing.T) {
+	values := []any{"binary", []any{uint64(1), "xml"}, map[string]any{"openstep": "yes"}}
+	formats := []int{BinaryFormat, XMLFormat, OpenStepFormat}
+
+	buf := &bytes.Buffer{}
+	for i, v := range values {
+		encoder := NewEncoderForFormat(buf, formats[i])
+		encoder.UseLengthPrefix()
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decoder := NewStreamDecoder(buf)
+	decoder.UseLengthPrefix()
+	for i, exp := range values {
+		var val any
+		if err := decoder.Decode(&val); err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if decoder.Format != formats[i] {
+			t.Errorf("document %d: expected format %s, got %s", i, FormatNames[formats[i]], FormatNames[decoder.Format])
+		}
+		if !reflect.DeepEqual(exp, val) {
+			t.Errorf("document %d: expected %#v, got %#v", i, exp, val)
+		}
+	}
+
+	var val any
+	if err := decoder.Decode(&val); err != io.EOF {
+		t.Errorf("expected io.EOF after the last document, got %v", err)
+	}
+
+	truncated := NewStreamDecoder(bytes.NewReader([]byte{0, 0, 0, 10, 'b', 'p'}))
+	truncated.UseLengthPrefix()
+	if err := truncated.Decode(&val); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated frame, got %v", err)
+	}
+
+	// A frame that claims to be 4 GiB long fails once its few bytes run out.
+	huge := NewStreamDecoder(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 'b', 'p'}))
+	huge.UseLengthPrefix()
+	if err := huge.Decode(&val); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for an oversized frame, got %v", err)
+	}
+
+	limited := NewStreamDecoder(bytes.NewReader([]byte{0, 0, 1, 0, 'b', 'p'}))
+	limited.UseLengthPrefix()
+	limited.SetOptions(DecoderOptions{MaxBytes: 255})
+	var limitErr *LimitError
+	if err := limited.Decode(&val); !errors.As(err, &limitErr) || limitErr.Limit != "MaxBytes" {
+		t.Errorf("expected a MaxBytes *LimitError for a frame longer than MaxBytes, got %v", err)
+	}
+}
+
+func TestDecodeDisallowUnknownKeys(t *testing.T) {
+	type socket struct {
+		Port int `plist:"SockServiceName"`
+	}
+	type job struct {
+		Label     string            `plist:"Label"`
+		Arguments []string          `plist:"ProgramArguments"`
+		Sockets   []socket          `plist:"Sockets"`
+		Env       map[string]socket `plist:"Env"`
+	}
+
+	doc := []byte(`{
+		Label = com.example.job;
+		ProgramArguments = (/bin/true);
+		RunAtLoad = 1;
+		Sockets = ({SockServiceName = 80;}, {SockServiceName = 443; SockFamily = IPv4;});
+		Env = {web = {SockServiceName = 8080; Typo = 1;};};
+	}`)
+
+	var lenient job
+	if err := NewDecoder(bytes.NewReader(doc)).Decode(&lenient); err != nil {
+		t.Fatalf("expected unknown keys to be ignored by default, got %v", err)
+	}
+
+	var strict job
+	decoder := NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownKeys()
+	err := decoder.Decode(&strict)
+	unknownErr, ok := err.(*UnknownKeyError)
+	if !ok {
+		t.Fatalf("expected an UnknownKeyError, got %v", err)
+	}
+
+	expected := []string{"Env.web.Typo", "RunAtLoad", "Sockets[1].SockFamily"}
+	sort.Strings(unknownErr.Paths)
+	if !reflect.DeepEqual(unknownErr.Paths, expected) {
+		t.Errorf("expected unknown keys %v, got %v", expected, unknownErr.Paths)
+	}
+	if !reflect.DeepEqual(strict, lenient) {
+		t.Errorf("expected known keys to be decoded anyway; got %#v", strict)
+	}
+
+	var loose map[string]any
+	decoder = NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownKeys()
+	if err := decoder.Decode(&loose); err != nil {
+		t.Errorf("expected maps to accept any key, got %v", err)
+	}
+}
+
+func TestDecodeRequiredKeys(t *testing.T) {
+	type socket struct {
+		Port   int    `plist:"SockServiceName,required"`
+		Family string `plist:"SockFamily"`
+	}
+	type job struct {
+		Label   string   `plist:"Label,required"`
+		Program string   `plist:"Program,required"`
+		Sockets []socket `plist:"Sockets"`
+	}
+	expected := []string{"Program", "Sockets[1].SockServiceName"}
+
+	subtest(t, "Decoder", func(t *testing.T) {
+		doc := []byte(`{Label = com.example.job; Sockets = ({SockServiceName = 80;}, {SockFamily = IPv4;});}`)
+		var val job
+		err := NewDecoder(bytes.NewReader(doc)).Decode(&val)
+		missingErr, ok := err.(*MissingKeyError)
+		if !ok {
+			t.Fatalf("expected a MissingKeyError, got %v", err)
+		}
+		if !reflect.DeepEqual(missingErr.Paths, expected) {
+			t.Errorf("expected missing keys %v, got %v", expected, missingErr.Paths)
+		}
+	})
+
+	subtest(t, "Dictionary", func(t *testing.T) {
+		dict := Dictionary{
+			"Label":   "com.example.job",
+			"Sockets": []any{map[string]any{"SockServiceName": uint64(80)}, map[string]any{"SockFamily": "IPv4"}},
+		}
+		var val job
+		err := dict.Unmarshal(&val)
+		missingErr, ok := err.(*MissingKeyError)
+		if !ok {
+			t.Fatalf("expected a MissingKeyError, got %v", err)
+		}
+		if !reflect.DeepEqual(missingErr.Paths, expected) {
+			t.Errorf("expected missing keys %v, got %v", expected, missingErr.Paths)
+		}
+	})
+
+	subtest(t, "Archiver", func(t *testing.T) {
+		type archivedSocket struct {
+			Family string `plist:"SockFamily"`
+		}
+		type archivedJob struct {
+			Label   string           `plist:"Label"`
+			Sockets []archivedSocket `plist:"Sockets"`
+		}
+		doc, err := (&Archiver{}).Marshal(&archivedJob{Label: "com.example.job", Sockets: []archivedSocket{{"IPv4"}, {"IPv6"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		archive := &Archiver{}
+		if err := archive.ReadFromData(doc); err != nil {
+			t.Fatal(err)
+		}
+		var val job
+		err = archive.Unmarshal(&val)
+		missingErr, ok := err.(*MissingKeyError)
+		if !ok {
+			t.Fatalf("expected a MissingKeyError, got %v", err)
+		}
+		expected := []string{"Program", "Sockets[0].SockServiceName", "Sockets[1].SockServiceName"}
+		if !reflect.DeepEqual(missingErr.Paths, expected) {
+			t.Errorf("expected missing keys %v, got %v", expected, missingErr.Paths)
+		}
+	})
+}
+
+func TestDecodeDefaultValues(t *testing.T) {
+	type info struct {
+		Identifier     string   `plist:"CFBundleIdentifier,default=com.example.app"`
+		MinimumVersion string   `plist:"LSMinimumSystemVersion,default=10.13"`
+		Build          int      `plist:"CFBundleVersion,default=1"`
+		Scale          *float64 `plist:"Scale,default=1.5"`
+		Background     bool     `plist:"LSBackgroundOnly,default=true"`
+	}
+	scale := 1.5
+	expected := info{"com.example.real", "10.13", 1, &scale, true}
+
+	subtest(t, "Decoder", func(t *testing.T) {
+		doc := []byte(`<plist><dict><key>CFBundleIdentifier</key><string>com.example.real</string></dict></plist>`)
+		var val info
+		if err := NewDecoder(bytes.NewReader(doc)).Decode(&val); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(val, expected) {
+			t.Errorf("expected %+v, got %+v", expected, val)
+		}
+	})
+
+	subtest(t, "Dictionary", func(t *testing.T) {
+		var val info
+		if err := (Dictionary{"CFBundleIdentifier": "com.example.real"}).Unmarshal(&val); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(val, expected) {
+			t.Errorf("expected %+v, got %+v", expected, val)
+		}
+	})
+
+	subtest(t, "Archiver", func(t *testing.T) {
+		type archivedInfo struct {
+			Identifier string `plist:"CFBundleIdentifier"`
+		}
+		doc, err := (&Archiver{}).Marshal(&archivedInfo{"com.example.real"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive := &Archiver{}
+		if err := archive.ReadFromData(doc); err != nil {
+			t.Fatal(err)
+		}
+		var val info
+		if err := archive.Unmarshal(&val); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(val, expected) {
+			t.Errorf("expected %+v, got %+v", expected, val)
+		}
+	})
+
+	subtest(t, "Invalid Default", func(t *testing.T) {
+		type counted struct {
+			Count int `plist:"Count,default=many"`
+		}
+		if _, err := GetTypeInfo(reflect.TypeOf(counted{})); err == nil || !strings.Contains(err.Error(), "field Count") {
+			t.Errorf("expected the tag to be rejected, got %v", err)
+		}
+		// The default is wrong even when the document does not need it.
+		var val counted
+		if err := NewDecoder(bytes.NewReader([]byte(`{Count = 2;}`))).Decode(&val); err == nil {
+			t.Error("expected an error for a default that is not an integer")
+		}
+		if _, err := Marshal(val, XMLFormat); err == nil {
+			t.Error("expected an error marshaling a type with an invalid default")
+		}
+	})
+}
+
+func TestDecodeRemainingKeys(t *testing.T) {
+	type info struct {
+		Identifier string         `plist:"CFBundleIdentifier"`
+		Version    string         `plist:"CFBundleVersion"`
+		Other      map[string]any `plist:",remain"`
+	}
+
+	doc := []byte(`<plist><dict>
+		<key>CFBundleIdentifier</key><string>com.example.app</string>
+		<key>CFBundleVersion</key><string>42</string>
+		<key>LSUIElement</key><true/>
+		<key>NSServices</key><array><string>one</string></array>
+	</dict></plist>`)
+
+	var val info
+	decoder := NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownKeys()
+	if err := decoder.Decode(&val); err != nil {
+		t.Fatalf("expected keys captured by the remain field to be known, got %v", err)
+	}
+
+	expected := map[string]any{"LSUIElement": true, "NSServices": []any{"one"}}
+	if !reflect.DeepEqual(val.Other, expected) {
+		t.Errorf("expected remaining keys %#v, got %#v", expected, val.Other)
+	}
+
+	// Struct fields take precedence over a stale copy in the remain map.
+	val.Other["CFBundleVersion"] = "stale"
+	out, err := Marshal(val, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip map[string]any
+	if _, err := Unmarshal(out, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	expected["CFBundleIdentifier"] = "com.example.app"
+	expected["CFBundleVersion"] = "42"
+	if !reflect.DeepEqual(roundTrip, expected) {
+		t.Errorf("expected %#v after re-encoding, got %#v", expected, roundTrip)
+	}
+
+	var invalid struct {
+		Other []any `plist:",remain"`
+	}
+	if _, err := Unmarshal(doc, &invalid); err == nil {
+		t.Error("expected an error for a remain field that is not a map")
+	}
+}
+
 func ExampleDecoder_Decode() {
 	type sparseBundleHeader struct {
 		InfoDictionaryVersion string `plist:"CFBundleInfoDictionaryVersion"`
@@ -0,0 +1,373 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// A ChangeType describes how a value differs between two property lists.
+type ChangeType int
+
+const (
+	// Added means the value is only present in the second property list.
+	Added ChangeType = iota + 1
+	// Removed means the value is only present in the first property list.
+	Removed
+	// Modified means the value has the same kind in both property lists, but a different value.
+	Modified
+	// TypeChanged means the value has a different kind in each property list.
+	TypeChanged
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case TypeChanged:
+		return "type changed"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// A Change is a single difference reported by Diff.
+type Change struct {
+	Type ChangeType
+	Path Path
+	// Old is the value in the first property list; it is invalid if Type is Added.
+	Old Value
+	// New is the value in the second property list; it is invalid if Type is Removed.
+	New Value
+}
+
+func (c Change) String() string {
+	path := c.Path.String()
+	if len(c.Path) == 0 {
+		path = "(root)"
+	}
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("%s: added %v", path, describeValue(c.New))
+	case Removed:
+		return fmt.Sprintf("%s: removed %v", path, describeValue(c.Old))
+	}
+	return fmt.Sprintf("%s: %v -> %v", path, describeValue(c.Old), describeValue(c.New))
+}
+
+// describeValue renders a value briefly, for Change.String.
+func describeValue(v Value) string {
+	switch v.Kind() {
+	case ArrayKind, DictionaryKind, SetKind, OrderedSetKind:
+		return fmt.Sprintf("%v (%d entries)", v.Kind(), v.Len())
+	case DataKind:
+		d, _ := v.AsData()
+		return fmt.Sprintf("data (%d bytes)", len(d))
+	case StringKind:
+		s, _ := v.AsString()
+		return fmt.Sprintf("string %q", s)
+	}
+	return fmt.Sprintf("%v %v", v.Kind(), v.Interface())
+}
+
+// Diff compares two property lists and reports how b differs from a.
+//
+// Dictionaries are compared key by key, regardless of key order. The elements of arrays and ordered
+// sets are aligned by their longest common subsequence, so that an element inserted at the front is
+// reported as added rather than as a change to every element after it; elements left over at the same
+// place in both are compared with each other. Within an array, removals are reported first, at their
+// indexes in a, then additions and changes at their indexes in b, so the changes can be applied in
+// the order reported (see PatchFromDiff). Very long arrays that differ in the middle are compared
+// element by element between their common prefix and suffix.
+//
+// Sets are compared regardless of order: members of a that b lacks are reported as removed,
+// at their indexes in a, and members of b that a lacks as added, at the end of the set.
+// Scalars are compared by value: integers and reals are equal if they are numerically equal,
+// however they were stored, but a change of kind (such as integer to string, or integer to UID)
+// is always reported as TypeChanged. Other changes are reported in path order, with dictionary keys sorted.
+func Diff(a, b Value) []Change {
+	var changes []Change
+	diffValues(Path{}, a, b, &changes)
+	return changes
+}
+
+// Equal reports whether v and o hold the same property list; see Diff.
+func (v Value) Equal(o Value) bool {
+	return equalValues(v.pval, o.pval)
+}
+
+func diffValues(path Path, a, b Value, changes *[]Change) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		*changes = append(*changes, Change{Type: Added, Path: path, New: b})
+		return
+	case !b.IsValid():
+		*changes = append(*changes, Change{Type: Removed, Path: path, Old: a})
+		return
+	case a.Kind() != b.Kind():
+		*changes = append(*changes, Change{Type: TypeChanged, Path: path, Old: a, New: b})
+		return
+	}
+
+	switch a.Kind() {
+	case DictionaryKind:
+		ad, bd := a.pval.(*cfDictionary), b.pval.(*cfDictionary)
+		aIndexes, bIndexes := ad.keyIndexes(), bd.keyIndexes()
+		keys := a.Keys()
+		for _, k := range bd.keys {
+			if _, ok := aIndexes[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var av, bv Value
+			if i, ok := aIndexes[k]; ok {
+				av = Value{ad.values[i]}
+			}
+			if j, ok := bIndexes[k]; ok {
+				bv = Value{bd.values[j]}
+			}
+			diffValues(path.child(k), av, bv, changes)
+		}
+	case ArrayKind, OrderedSetKind:
+		partners := alignArrays(a.pval.(*cfArray).values, b.pval.(*cfArray).values)
+		paired := make([]bool, a.Len())
+		for _, i := range partners {
+			if i >= 0 {
+				paired[i] = true
+			}
+		}
+		for i, ok := range paired {
+			if !ok {
+				*changes = append(*changes, Change{Type: Removed, Path: path.child(fmt.Sprint(i)), Old: a.Index(i)})
+			}
+		}
+		for j, i := range partners {
+			if i < 0 {
+				*changes = append(*changes, Change{Type: Added, Path: path.child(fmt.Sprint(j)), New: b.Index(j)})
+			} else {
+				diffValues(path.child(fmt.Sprint(j)), a.Index(i), b.Index(j), changes)
+			}
+		}
+	case SetKind:
+		matched := matchSetMembers(a.pval.(*cfArray), b.pval.(*cfArray))
+		kept := 0
+		for i, j := range matched {
+			if j < 0 {
+				*changes = append(*changes, Change{Type: Removed, Path: path.child(fmt.Sprint(i)), Old: a.Index(i)})
+			} else {
+				kept++
+			}
+		}
+		inA := make([]bool, b.Len())
+		for _, j := range matched {
+			if j >= 0 {
+				inA[j] = true
+			}
+		}
+		for j, ok := range inA {
+			if !ok {
+				*changes = append(*changes, Change{Type: Added, Path: path.child(fmt.Sprint(kept)), New: b.Index(j)})
+				kept++
+			}
+		}
+	default:
+		if !equalValues(a.pval, b.pval) {
+			*changes = append(*changes, Change{Type: Modified, Path: path, Old: a, New: b})
+		}
+	}
+}
+
+// child returns a new path for seg within p, which does not share p's storage.
+func (p Path) child(seg string) Path {
+	return append(p[:len(p):len(p)], seg)
+}
+
+// maxAlignCells bounds the table alignArrays builds to find a longest common subsequence.
+const maxAlignCells = 1 << 20
+
+// alignArrays pairs the elements of b with elements of a, keeping their order: equal elements
+// along a longest common subsequence, then the unequal elements left between them, one for one.
+// The result holds, for each element of b, the index of its partner in a, or -1 if it has none.
+func alignArrays(a, b []cfValue) []int {
+	partners := make([]int, len(b))
+	for j := range partners {
+		partners[j] = -1
+	}
+
+	// Most edits leave the two ends alone, and trimming them keeps the table small.
+	start := 0
+	for start < len(a) && start < len(b) && equalValues(a[start], b[start]) {
+		partners[start] = start
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && equalValues(a[endA-1], b[endB-1]) {
+		endA--
+		endB--
+		partners[endB] = endA
+	}
+
+	// matches holds the pairs of equal elements between the prefix and suffix, in order.
+	var matches [][2]int
+	n, m := endA-start, endB-start
+	if n > 0 && m > 0 && int64(n)*int64(m) <= maxAlignCells {
+		// lengths[i*(m+1)+j] is the length of the longest common subsequence of a[start+i:endA] and b[start+j:endB].
+		equal := make([]bool, n*m)
+		lengths := make([]int, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if equalValues(a[start+i], b[start+j]) {
+					equal[i*m+j] = true
+					lengths[i*(m+1)+j] = lengths[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lengths[i*(m+1)+j] = max(lengths[(i+1)*(m+1)+j], lengths[i*(m+1)+j+1])
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			switch {
+			case equal[i*m+j]:
+				matches = append(matches, [2]int{start + i, start + j})
+				i++
+				j++
+			case lengths[(i+1)*(m+1)+j] >= lengths[i*(m+1)+j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+
+	i, j := start, start
+	pairUpTo := func(endI, endJ int) {
+		for ; i < endI && j < endJ; i, j = i+1, j+1 {
+			partners[j] = i
+		}
+		i, j = endI, endJ
+	}
+	for _, match := range matches {
+		pairUpTo(match[0], match[1])
+		partners[j] = i
+		i, j = i+1, j+1
+	}
+	pairUpTo(endA, endB)
+	return partners
+}
+
+// keyIndexes maps each key of the dictionary to its index, for comparing dictionaries in linear time.
+func (p *cfDictionary) keyIndexes() map[string]int {
+	indexes := make(map[string]int, len(p.keys))
+	for i := len(p.keys) - 1; i >= 0; i-- {
+		// Like index, prefer the first of duplicated keys.
+		indexes[p.keys[i]] = i
+	}
+	return indexes
+}
+
+// matchSetMembers pairs each member of set a with an equal member of set b, regardless of order.
+// The result holds, for each member of a, the index of its partner in b, or -1 if it has none.
+func matchSetMembers(a, b *cfArray) []int {
+	used := make([]bool, len(b.values))
+	matched := make([]int, len(a.values))
+	for i, av := range a.values {
+		matched[i] = -1
+		for j, bv := range b.values {
+			if !used[j] && equalValues(av, bv) {
+				used[j] = true
+				matched[i] = j
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// equalValues compares two property list values as described by Diff.
+func equalValues(a, b cfValue) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case cfString:
+		b, ok := b.(cfString)
+		return ok && a == b
+	case *cfNumber:
+		b, ok := b.(*cfNumber)
+		if !ok {
+			return false
+		}
+		// A negative signed integer is never equal to an unsigned one.
+		return a.int128() == b.int128()
+	case *cfReal:
+		b, ok := b.(*cfReal)
+		if !ok {
+			return false
+		}
+		if math.IsNaN(a.value) && math.IsNaN(b.value) {
+			return true
+		}
+		return a.value == b.value
+	case cfBoolean:
+		b, ok := b.(cfBoolean)
+		return ok && a == b
+	case cfData:
+		b, ok := b.(cfData)
+		return ok && bytes.Equal(a, b)
+	case cfDate:
+		b, ok := b.(cfDate)
+		return ok && time.Time(a).Equal(time.Time(b))
+	case cfUID:
+		b, ok := b.(cfUID)
+		return ok && a == b
+	case cfNull:
+		_, ok := b.(cfNull)
+		return ok
+	case cfURL:
+		b, ok := b.(cfURL)
+		return ok && a == b
+	case cfUUID:
+		b, ok := b.(cfUUID)
+		return ok && a == b
+	case *cfArray:
+		b, ok := b.(*cfArray)
+		if !ok || a.set != b.set || len(a.values) != len(b.values) {
+			return false
+		}
+		if a.set == cfUnorderedSet {
+			for _, j := range matchSetMembers(a, b) {
+				if j < 0 {
+					return false
+				}
+			}
+			return true
+		}
+		for i := range a.values {
+			if !equalValues(a.values[i], b.values[i]) {
+				return false
+			}
+		}
+		return true
+	case *cfDictionary:
+		b, ok := b.(*cfDictionary)
+		if !ok || len(a.keys) != len(b.keys) {
+			return false
+		}
+		indexes := b.keyIndexes()
+		for i, k := range a.keys {
+			j, ok := indexes[k]
+			if !ok || !equalValues(a.values[i], b.values[j]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]any{
+		"com.apple.security.app-sandbox": true,
+		"com.apple.developer.team":       "ABCDE",
+		"keychain-access-groups":         []any{"group.one", "group.two"},
+		"version":                        uint64(3),
+		"archive":                        UID(1),
+		"nested":                         map[string]any{"unchanged": 1.5, "removed": "x"},
+	}
+	after := map[string]any{
+		"com.apple.security.app-sandbox": true,
+		"com.apple.developer.team":       "FGHIJ",
+		"keychain-access-groups":         []any{"group.one", "group.three", "group.four"},
+		"version":                        "3",
+		"archive":                        uint64(1),
+		"nested":                         map[string]any{"unchanged": float32(1.5), "added": false},
+	}
+
+	aDoc, err := Marshal(before, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bDoc, err := Marshal(after, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a, b Value
+	if err := NewDecoder(bytes.NewReader(aDoc)).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDecoder(bytes.NewReader(bDoc)).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		Type ChangeType
+		Path string
+	}
+	expected := []change{
+		{TypeChanged, "/archive"},
+		{Modified, "/com.apple.developer.team"},
+		{Modified, "/keychain-access-groups/1"},
+		{Added, "/keychain-access-groups/2"},
+		{Added, "/nested/added"},
+		{Removed, "/nested/removed"},
+		{TypeChanged, "/version"},
+	}
+
+	var got []change
+	for _, c := range Diff(a, b) {
+		got = append(got, change{c.Type, c.Path.String()})
+		if (c.Type == Added) == c.Old.IsValid() || (c.Type == Removed) == c.New.IsValid() {
+			t.Errorf("unexpected values for %v", c)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected changes %v, got %v", expected, got)
+	}
+
+	if changes := Diff(a, a.Clone()); len(changes) != 0 {
+		t.Errorf("expected no changes between a document and its clone, got %v", changes)
+	}
+	if !a.Equal(a.Clone()) || a.Equal(b) {
+		t.Error("expected Equal to agree with Diff")
+	}
+}
+
+func TestDiffIntegerSignedness(t *testing.T) {
+	if !NewInt(5).Equal(NewUint(5)) {
+		t.Error("expected signed and unsigned 5 to be equal")
+	}
+	if NewInt(-1).Equal(NewUint(1<<64 - 1)) {
+		t.Error("expected -1 and 1<<64-1 to differ")
+	}
+	changes := Diff(NewInt(-1), NewUint(1<<64-1))
+	if len(changes) != 1 || changes[0].Type != Modified || changes[0].String() != "(root): integer -1 -> integer 18446744073709551615" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestDiffSets(t *testing.T) {
+	a, b, c := NewString("a"), NewString("b"), NewString("c")
+	if changes := Diff(NewArray(a, b), NewSet(a, b)); len(changes) != 1 || changes[0].Type != TypeChanged {
+		t.Errorf("expected an array and a set to differ in type, got %v", changes)
+	}
+	if NewOrderedSet(a, b).Equal(NewSet(a, b)) {
+		t.Error("expected an ordered set and a set to differ")
+	}
+
+	if !NewSet(a, b).Equal(NewSet(b, a)) {
+		t.Error("expected sets to be equal regardless of order")
+	}
+	if NewOrderedSet(a, b).Equal(NewOrderedSet(b, a)) {
+		t.Error("expected ordered sets to compare in order")
+	}
+
+	before, after := NewSet(a, b, c), NewSet(c, NewString("d"), a)
+	changes := Diff(before, after)
+	if len(changes) != 2 || changes[0].String() != `/1: removed string "b"` || changes[1].String() != `/2: added string "d"` {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	patched, err := PatchFromDiff(changes).Apply(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !patched.Equal(after) || patched.Kind() != SetKind {
+		t.Errorf("expected the patch to produce %v, got %v", after.Interface(), patched.Interface())
+	}
+}
+
+func TestDiffArrays(t *testing.T) {
+	for _, test := range []struct {
+		Name     string
+		Before   []any
+		After    []any
+		Expected []string
+	}{
+		{"Insert at Front", []any{"b", "c", "d"}, []any{"a", "b", "c", "d"}, []string{`/0: added string "a"`}},
+		{"Remove from Middle", []any{"a", "b", "c", "d"}, []any{"a", "c", "d"}, []string{`/1: removed string "b"`}},
+		{"Change in Place", []any{"a", "b", "c"}, []any{"a", "x", "c"}, []string{`/1: string "b" -> string "x"`}},
+		{"Shorter", []any{1, 2, 3, 4}, []any{1, 5}, []string{"/2: removed integer 3", "/3: removed integer 4", "/1: integer 2 -> integer 5"}},
+		{"Move", []any{"a", "b", "c"}, []any{"c", "a", "b"}, []string{`/2: removed string "c"`, `/0: added string "c"`}},
+		{"Nested", []any{"head", map[string]any{"k": 1}}, []any{"new", "head", map[string]any{"k": 2}}, []string{`/0: added string "new"`, "/2/k: integer 1 -> integer 2"}},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			before, after := mustValueOf(t, test.Before), mustValueOf(t, test.After)
+			changes := Diff(before, after)
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("expected changes %q, got %q", test.Expected, got)
+			}
+
+			patched, err := PatchFromDiff(changes).Apply(before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !patched.Equal(after) {
+				t.Errorf("expected the patch to produce %v, got %v", after.Interface(), patched.Interface())
+			}
+		})
+	}
+}
@@ -2,15 +2,23 @@ package plist
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"runtime"
+	"time"
 )
 
 type generator interface {
 	generateDocument(cfValue)
 	Indent(string)
+	PreserveKeyOrder()
+	DatePrecision(time.Duration)
+	PreserveFloat32()
+	Canonical()
 }
 
 // An Encoder writes a property list to an output stream.
@@ -19,6 +27,50 @@ type Encoder struct {
 	format int
 
 	indent string
+
+	preserveKeyOrder bool
+	datePrecision    time.Duration
+	preserveFloat32  bool
+	canonical        bool
+	nilPolicy        NilPolicy
+	lengthPrefixed   bool
+	cancel           cancellation
+
+	path keyPath // location of the value being marshaled
+}
+
+// A NilPolicy says what an Encoder does with a nil pointer or interface inside an array,
+// dictionary or struct. A nil slice or map is not nil in this sense: it is encoded as an
+// empty array or dictionary. A struct field tagged omitempty is left out when it is nil,
+// whatever the policy.
+type NilPolicy int
+
+const (
+	// NilSkip leaves nil values out: a dictionary has no entry for them, and an array is
+	// shorter by one element. As it always has been, a nil pointer to a struct is encoded
+	// as an empty dictionary instead. This is the default.
+	NilSkip NilPolicy = iota
+
+	// NilError makes a nil value an error, a *NilValueError giving its location.
+	NilError
+
+	// NilPlaceholder writes a placeholder in place of a nil value: the null of a binary
+	// property list, or an empty string in XML and text property lists, which have no null.
+	// An Archiver writes a reference to its $null object. A Decoder reads the null back as nil,
+	// but the empty string of an XML or text property list as an empty string, as it cannot tell
+	// a placeholder from a real one. Note that the text property list parser skips empty strings
+	// in arrays, so placeholders there do not survive a round trip.
+	NilPlaceholder
+)
+
+// A NilValueError is returned by an Encoder with the NilError policy when it finds a nil value.
+type NilValueError struct {
+	// Path is the location of the nil value, such as "Program.Arguments[2]".
+	Path string
+}
+
+func (e *NilValueError) Error() string {
+	return fmt.Sprintf("plist: nil value at %q", e.Path)
 }
 
 // Encode writes the property list encoding of v to the stream.
@@ -32,25 +84,128 @@ func (p *Encoder) Encode(v any) (err error) {
 		}
 	}()
 
+	p.path = p.path[:0]
 	pval := p.marshal(reflect.ValueOf(v))
 	if pval == nil {
 		panic(errors.New("plist: no root element to encode"))
 	}
 
+	w := p.writer
+	var frame *bytes.Buffer
+	if p.lengthPrefixed {
+		// The length has to be written first, so the document is generated in memory.
+		frame = &bytes.Buffer{}
+		w = frame
+	}
+
 	var g generator
 	switch p.format {
 	case XMLFormat:
-		g = newXMLPlistGenerator(p.writer)
+		g = newXMLPlistGenerator(w)
 	case BinaryFormat, AutomaticFormat:
-		g = newBplistGenerator(p.writer)
+		g = newBplistGenerator(w)
 	case OpenStepFormat, GNUStepFormat:
-		g = newTextPlistGenerator(p.writer, p.format)
+		g = newTextPlistGenerator(w, p.format)
+	default:
+		panic(fmt.Errorf("plist: unsupported output format %d", p.format))
 	}
 	g.Indent(p.indent)
+	if p.preserveKeyOrder && !p.canonical {
+		g.PreserveKeyOrder()
+	}
+	g.DatePrecision(p.datePrecision)
+	if p.preserveFloat32 {
+		g.PreserveFloat32()
+	}
+	if p.canonical {
+		g.Canonical()
+	}
 	g.generateDocument(pval)
+
+	if frame != nil {
+		return p.writeFrame(frame.Bytes())
+	}
 	return
 }
 
+// PreserveKeyOrder causes the Encoder to write dictionary keys in the order they were
+// given, rather than sorting them: struct fields in the order they are declared, an
+// OrderedDictionary or Value in its own order, and (as Go maps have no order) the
+// keys of a map sorted. Without it, every dictionary's keys are sorted.
+func (p *Encoder) PreserveKeyOrder() {
+	p.preserveKeyOrder = true
+}
+
+// DatePrecision causes the Encoder to truncate every date it writes to a multiple of d.
+// By default, dates are written as precisely as the format allows: XML and text property
+// lists write fractional seconds when a date has them, and binary property lists store
+// dates as floating-point seconds. Apple's tools write XML and text dates in whole seconds,
+// and older versions of them reject anything else; DatePrecision(time.Second) matches them.
+func (p *Encoder) DatePrecision(d time.Duration) {
+	p.datePrecision = d
+}
+
+// PreserveFloat32 causes the Encoder to write 32-bit reals to XML and text property lists in the
+// fewest digits that identify them as 32-bit reals (0.1 rather than 0.10000000149011612), so
+// that a Decoder with PreserveFloat32 can tell them apart from 64-bit reals. A 64-bit real
+// that would be mistaken for a 32-bit one is written with a trailing zero (1.0e+00 rather
+// than 1). Binary property lists keep 32-bit reals regardless.
+func (p *Encoder) PreserveFloat32() {
+	p.preserveFloat32 = true
+}
+
+// Canonical causes the Encoder to write the canonical form of a document, so that equal
+// documents are encoded byte for byte the same, by this and every later version of the
+// package; the output is suitable for signing or content addressing. Dictionary keys are
+// sorted, whether or not PreserveKeyOrder was called, by their bytes. Set members keep
+// their order. In XML and text property lists, nothing else changes.
+//
+// A canonical binary property list is written as follows:
+//
+//   - Values with the same contents share one object, whatever their type: two strings, two
+//     arrays or two dictionaries are the same object when they are equal. Values are equal
+//     when they would be encoded the same way, so a signed and an unsigned integer of the
+//     same value are equal, as are two dates at the same instant, and two reals are equal
+//     only when they have the same width and bits (a NaN equals itself, 0 does not equal -0).
+//   - Objects are numbered in the order they are first reached by a depth-first walk from
+//     the top-level value, which is object 0. A dictionary's keys are visited before its
+//     values, each in key order; an array's or set's members are visited in order.
+//   - Objects are written in the order of their numbers, immediately after the header.
+//   - Integers use the fewest bytes the format allows: 1, 2 or 4 bytes for non-negative
+//     integers below 2^32, 8 bytes for other integers that fit in 64 signed bits, and 16
+//     otherwise. Reals keep their width, and dates are 64-bit reals.
+//   - Strings are ASCII strings if every character is ASCII, and UTF-16 strings otherwise.
+//   - Object references, and offsets in the offset table, use the fewest bytes (1, 2, 4 or 8)
+//     that hold the largest object number and the largest object offset.
+//   - The trailer's unused bytes are zero.
+func (p *Encoder) Canonical() {
+	p.canonical = true
+}
+
+// SetNilPolicy sets what the Encoder does with nil values inside arrays, dictionaries and structs.
+func (p *Encoder) SetNilPolicy(policy NilPolicy) {
+	p.nilPolicy = policy
+}
+
+// UseLengthPrefix causes the Encoder to precede every property list it writes with
+// the document's length in bytes, as a 4-byte big-endian integer.
+func (p *Encoder) UseLengthPrefix() {
+	p.lengthPrefixed = true
+}
+
+func (p *Encoder) writeFrame(doc []byte) error {
+	if uint64(len(doc)) > math.MaxUint32 {
+		return errors.New("plist: document too large for a 4-byte length prefix")
+	}
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(doc)))
+	if _, err := p.writer.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := p.writer.Write(doc)
+	return err
+}
+
 // Indent turns on pretty-printing for the XML and Text property list formats.
 // Each element begins on a new line and is preceded by one or more copies of indent according to its nesting depth.
 func (p *Encoder) Indent(indent string) {
@@ -85,13 +240,15 @@ func NewBinaryEncoder(w io.Writer) *Encoder {
 // the property list format bears no representation for nil values.
 //
 // Strings, integers of varying size, floats and booleans are encoded unchanged.
+// Integers of up to 128 bits may be given as plist.Int128, big.Int or *big.Int; a larger big.Int is an error.
 // Strings bearing non-ASCII runes will be encoded differently depending upon the property list format:
 // UTF-8 for XML property lists and UTF-16 for binary property lists.
 //
 // Slice and Array values are encoded as property list arrays, except for
 // []byte values, which are encoded as data.
 //
-// Map values encode as dictionaries. The map's key type must be string; there is no provision for encoding non-string dictionary keys.
+// Map values encode as dictionaries. As with encoding/json, the map's key type must be a string, an integer or an
+// encoding.TextMarshaler; booleans are accepted too. Integer and boolean keys are written in decimal and as "true" and "false".
 //
 // Struct values are encoded as dictionaries, with only exported fields being serialized. Struct field encoding may be influenced with the use of tags.
 // The tag format is:
@@ -0,0 +1,66 @@
+package plist
+
+import (
+	"math/big"
+)
+
+// An Int128 is a signed 128-bit integer in two's complement: Hi holds the high 64 bits,
+// including the sign, and Lo the low 64 bits. Binary property lists can store integers of
+// this size; XML and text property lists store them in decimal, like any other integer.
+//
+// Marshal and Unmarshal also accept *big.Int and big.Int for integers of up to 128 bits.
+type Int128 struct {
+	Hi int64
+	Lo uint64
+}
+
+var (
+	minInt128 = new(big.Int).Lsh(big.NewInt(-1), 127)
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+)
+
+// Int128FromInt64 returns i as an Int128.
+func Int128FromInt64(i int64) Int128 {
+	return Int128{Hi: i >> 63, Lo: uint64(i)}
+}
+
+// Int128FromUint64 returns u as an Int128.
+func Int128FromUint64(u uint64) Int128 {
+	return Int128{Lo: u}
+}
+
+// Int128FromBig returns b as an Int128. It reports false if b does not fit in 128 bits.
+func Int128FromBig(b *big.Int) (Int128, bool) {
+	if b.Cmp(minInt128) < 0 || b.Cmp(maxInt128) > 0 {
+		return Int128{}, false
+	}
+	// Two's complement of a negative b is 2^128 + b.
+	u := new(big.Int).Set(b)
+	if b.Sign() < 0 {
+		u.Add(u, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	lo := new(big.Int).And(u, new(big.Int).SetUint64(^uint64(0)))
+	hi := new(big.Int).Rsh(u, 64)
+	return Int128{Hi: int64(hi.Uint64()), Lo: lo.Uint64()}, true
+}
+
+// Big returns i as a big.Int.
+func (i Int128) Big() *big.Int {
+	b := new(big.Int).Lsh(big.NewInt(i.Hi), 64)
+	return b.Or(b, new(big.Int).SetUint64(i.Lo))
+}
+
+// IsInt64 reports whether i fits in an int64.
+func (i Int128) IsInt64() bool {
+	return i.Hi == int64(i.Lo)>>63
+}
+
+// IsUint64 reports whether i fits in a uint64.
+func (i Int128) IsUint64() bool {
+	return i.Hi == 0
+}
+
+// String returns i in decimal.
+func (i Int128) String() string {
+	return i.Big().String()
+}
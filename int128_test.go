@@ -0,0 +1,135 @@
+package plist
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func bigFromString(s string) *big.Int {
+	b, _ := new(big.Int).SetString(s, 10)
+	return b
+}
+
+func TestInt128Big(t *testing.T) {
+	for _, s := range []string{
+		"0", "1", "-1",
+		"9223372036854775807", "-9223372036854775808",
+		"18446744073709551615", "18446744073709551616", "-18446744073709551617",
+		"170141183460469231731687303715884105727", "-170141183460469231731687303715884105728",
+	} {
+		subtest(t, s, func(t *testing.T) {
+			i, ok := Int128FromBig(bigFromString(s))
+			if !ok {
+				t.Fatal("expected the integer to fit")
+			}
+			if got := i.String(); got != s {
+				t.Errorf("expected %s, got %s", s, got)
+			}
+		})
+	}
+
+	for _, s := range []string{"170141183460469231731687303715884105728", "-170141183460469231731687303715884105729"} {
+		if _, ok := Int128FromBig(bigFromString(s)); ok {
+			t.Errorf("expected %s not to fit", s)
+		}
+	}
+
+	if i := Int128FromInt64(-2); i != (Int128{Hi: -1, Lo: math.MaxUint64 - 1}) || !i.IsInt64() || i.IsUint64() {
+		t.Errorf("unexpected Int128 %#v", i)
+	}
+}
+
+func TestInt128RoundTrip(t *testing.T) {
+	type integers struct {
+		Large    Int128
+		Negative *big.Int
+		Small    int64
+	}
+	large, _ := Int128FromBig(bigFromString("123456789012345678901234567890"))
+	value := integers{
+		Large:    large,
+		Negative: bigFromString("-98765432109876543210"),
+		Small:    -5,
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(value, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got integers
+			if _, err := Unmarshal(doc, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Errorf("expected %v, got %v", value, got)
+			}
+
+			if format == OpenStepFormat {
+				// OpenStep property lists store integers as strings.
+				return
+			}
+			var generic map[string]any
+			if _, err := Unmarshal(doc, &generic); err != nil {
+				t.Fatal(err)
+			}
+			if generic["Large"] != large {
+				t.Errorf("expected %v, got %#v", large, generic["Large"])
+			}
+		})
+	}
+}
+
+func TestBplistInt128Wide(t *testing.T) {
+	// A 16-byte integer whose high half is not a sign extension of its low half.
+	object := []byte{bpTagInteger | 0x4, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}
+	var got Int128
+	if _, err := Unmarshal(singleObjectBplist(object), &got); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Int128{Hi: 1, Lo: 2}); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	doc, err := Marshal(got, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, singleObjectBplist(object)) {
+		t.Errorf("expected %x, got %x", singleObjectBplist(object), doc)
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	for _, test := range []struct {
+		Name string
+		Doc  string
+		Into any
+	}{
+		{"Wide into uint64", "<plist><integer>18446744073709551616</integer></plist>", new(uint64)},
+		{"Wide into int64", "<plist><integer>-9223372036854775809</integer></plist>", new(int64)},
+		{"Large into uint8", "<plist><integer>256</integer></plist>", new(uint8)},
+		{"Negative into uint", "<plist><integer>-1</integer></plist>", new(uint)},
+		{"Large into int16", "<plist><integer>40000</integer></plist>", new(int16)},
+		{"Large string into int8", "(\"300\")", new([]int8)},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte(test.Doc), test.Into); err == nil {
+				t.Errorf("expected an error, got %v", reflect.ValueOf(test.Into).Elem())
+			}
+		})
+	}
+
+	tooLarge := []byte("<plist><integer>340282366920938463463374607431768211456</integer></plist>")
+	if _, err := Unmarshal(tooLarge, new(Int128)); err == nil {
+		t.Error("expected an error for an integer larger than 128 bits")
+	}
+
+	if _, err := Marshal(bigFromString("340282366920938463463374607431768211456"), XMLFormat); err == nil {
+		t.Error("expected an error marshaling a big.Int larger than 128 bits")
+	}
+}
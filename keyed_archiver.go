@@ -14,13 +14,6 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
-const (
-	secondsPerMinute       = 60
-	secondsPerHour         = 60 * secondsPerMinute
-	secondsPerDay          = 24 * secondsPerHour
-	unixToCocoa      int64 = (31*365 + 31/4 + 1) * secondsPerDay
-)
-
 type archiverDate struct {
 	Time  float64 `plist:"NS.time"`
 	Class UID     `plist:"$class"`
@@ -97,6 +90,20 @@ type Archiver struct {
 	Objects  []any        `plist:"$objects"`
 	Archiver string       `plist:"$archiver"`
 	Top      *archiverTop `plist:"$top"`
+
+	path    keyPath  // location of the value being marshaled or unmarshaled
+	missing []string // paths of required keys that were absent
+
+	nilPolicy    NilPolicy
+	nilPolicySet bool // without a policy, a nil value is an error
+}
+
+// SetNilPolicy sets what Marshal does with nil values inside arrays and structs.
+// NilPlaceholder refers to the archive's $null object. Without a policy, a nil
+// value is an error, unless it is a struct field tagged omitempty.
+func (a *Archiver) SetNilPolicy(policy NilPolicy) {
+	a.nilPolicy = policy
+	a.nilPolicySet = true
 }
 
 // ReadFromZipData 从压缩数据读取
@@ -143,9 +150,24 @@ func (a *Archiver) addObject(obj any) UID {
 
 // Unmarshal 序列化
 func (a *Archiver) Unmarshal(v any) error {
-	return a.unmarshal(a.Objects[a.Top.Root], reflect.ValueOf(v))
+	a.path, a.missing = nil, nil
+	if err := a.unmarshal(a.Objects[a.Top.Root], reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	if len(a.missing) > 0 {
+		return &MissingKeyError{Paths: a.missing}
+	}
+	return nil
 }
 func (a *Archiver) unmarshal(v any, val reflect.Value) error {
+	if v == "$null" && val.CanSet() {
+		// A reference to the $null object, as written for a nil value, clears anything that can be nil.
+		switch val.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+	}
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			val.Set(reflect.New(val.Type().Elem()))
@@ -265,8 +287,7 @@ func (a *Archiver) unmarshalDate(pval map[string]any, val reflect.Value) error {
 	if err := Dictionary(pval).Unmarshal(date); err != nil {
 		return err
 	}
-	vt := time.Unix(int64(date.Time)+unixToCocoa, 0)
-	val.Set(reflect.ValueOf(vt))
+	val.Set(reflect.ValueOf(timeFromAppleSeconds(date.Time)))
 	return nil
 }
 func (a *Archiver) unmarshalData(pval map[string]any, val reflect.Value) error {
@@ -289,7 +310,9 @@ func (a *Archiver) unmarshalSlice(array []any, val reflect.Value) error {
 	new := reflect.MakeSlice(val.Type(), len(array), len(array))
 	val.Set(new)
 	for i, v := range array {
+		a.path.pushIndex(i)
 		a.unmarshal(v, val.Index(i))
+		a.path.pop()
 	}
 	return nil
 }
@@ -298,12 +321,14 @@ func (a *Archiver) unmarshalArray(dict map[string]any, val reflect.Value) error
 	if err := Dictionary(dict).Unmarshal(arr); err != nil {
 		return err
 	}
-	for _, v := range arr.Objects {
+	for i, v := range arr.Objects {
 		if vuid, ok := v.(UID); ok {
 			item := reflect.New(val.Type().Elem())
+			a.path.pushIndex(i)
 			if err := a.unmarshal(a.Objects[vuid], item); err != nil {
 				return err
 			}
+			a.path.pop()
 			val.Set(reflect.Append(val, item.Elem()))
 		} else {
 			val.Set(reflect.Append(val, reflect.ValueOf(v)))
@@ -317,13 +342,24 @@ func (a *Archiver) unmarshalNSType(pval map[string]any, val reflect.Value) error
 		return err
 	}
 	for _, finfo := range tinfo.Fields {
-		value := pval[finfo.Name]
+		value, ok := pval[finfo.Name]
+		if !ok {
+			if finfo.Required {
+				a.missing = append(a.missing, a.path.child(finfo.Name))
+			} else if finfo.HasDefault {
+				if err := unmarshalDefault(&finfo, finfo.Value(val)); err != nil {
+					return err
+				}
+			}
+		}
 		if uindex, ok := value.(UID); ok {
 			value = a.Objects[uindex]
 		}
+		a.path.pushKey(finfo.Name)
 		if err = a.unmarshal(value, finfo.Value(val)); err != nil {
 			return err
 		}
+		a.path.pop()
 	}
 	return nil
 }
@@ -349,11 +385,17 @@ func (a *Archiver) unmarshalStruct(dict map[string]any, val reflect.Value) error
 	}
 	for _, finfo := range tinfo.Fields {
 		if dval, ok := kvs[finfo.Name]; ok {
+			a.path.pushKey(finfo.Name)
 			if err := a.unmarshal(dval, finfo.Value(val)); err != nil {
 				return err
 			}
-		} else if !finfo.OmitEmpty {
-			//return fmt.Errorf("field[%s] can not empty", finfo.name)
+			a.path.pop()
+		} else if finfo.Required {
+			a.missing = append(a.missing, a.path.child(finfo.Name))
+		} else if finfo.HasDefault {
+			if err := unmarshalDefault(&finfo, finfo.Value(val)); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -365,6 +407,7 @@ func (a *Archiver) Marshal(v any) ([]byte, error) {
 	a.Archiver = "NSKeyedArchiver"
 	a.Objects = make([]any, 0)
 	a.addObject("$null")
+	a.path = a.path[:0]
 	index, err := a.marshal(reflect.ValueOf(v))
 	if err != nil {
 		return nil, err
@@ -377,12 +420,40 @@ func (a *Archiver) Marshal(v any) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// marshalNil applies the nil policy to a nil value. It returns errArchiverNilElem if the value is to be left out,
+// which callers turn into an error if they cannot leave it out.
+func (a *Archiver) marshalNil() (UID, error) {
+	if a.nilPolicySet {
+		switch a.nilPolicy {
+		case NilError:
+			return 0, &NilValueError{Path: a.path.String()}
+		case NilPlaceholder:
+			return UID(0), nil
+		}
+	}
+	return 0, errArchiverNilElem
+}
+
+// isNil reports whether val is a nil pointer or interface.
+func isNil(val reflect.Value) bool {
+	return (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil()
+}
+
+// skipsNil reports whether nil values are left out of arrays and structs.
+func (a *Archiver) skipsNil() bool {
+	return a.nilPolicySet && a.nilPolicy == NilSkip
+}
+
 func (a *Archiver) marshal(val reflect.Value) (UID, error) {
-	if val.Kind() == reflect.Ptr {
+	if val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
 		if val.IsNil() {
-			return UID(0), errArchiverNilElem
+			return a.marshalNil()
 		}
 		val = val.Elem()
+		if val.Kind() == reflect.Ptr {
+			return a.marshal(val)
+		}
 	}
 	switch val.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
@@ -416,7 +487,7 @@ func (a *Archiver) marshal(val reflect.Value) (UID, error) {
 	case reflect.Struct:
 		if val.Type() == archiverDateType {
 			date := &archiverDate{}
-			date.Time = float64(val.Interface().(time.Time).Unix() - unixToCocoa)
+			date.Time = appleSeconds(val.Interface().(time.Time))
 			date.Class = a.addObject(archiverDateClass)
 			return a.addObject(date), nil
 		}
@@ -433,8 +504,13 @@ func (a *Archiver) marshalSlice(val reflect.Value) (UID, error) {
 	}
 	arr := &archiverArray{}
 	for i := 0; i < val.Len(); i++ {
+		a.path.pushIndex(i)
 		valueIndex, err := a.marshal(val.Index(i))
+		a.path.pop()
 		if err != nil {
+			if err == errArchiverNilElem && a.skipsNil() {
+				continue
+			}
 			return 0, err
 		}
 		arr.Objects = append(arr.Objects, valueIndex)
@@ -451,8 +527,17 @@ func (a *Archiver) marshalStruct(val reflect.Value) (UID, error) {
 	if class, ok := archiverClasses[typ]; ok {
 		nsobj := make(map[string]any)
 		for _, ti := range tinfo.Fields {
-			valueIndex, err := a.marshal(ti.Value(val))
+			fv := ti.Value(val)
+			if ti.OmitEmpty && isNil(fv) {
+				continue
+			}
+			a.path.pushKey(ti.Name)
+			valueIndex, err := a.marshal(fv)
+			a.path.pop()
 			if err != nil {
+				if err == errArchiverNilElem && a.skipsNil() {
+					continue
+				}
 				return 0, err
 			}
 			nsobj[ti.Name] = valueIndex
@@ -462,9 +547,15 @@ func (a *Archiver) marshalStruct(val reflect.Value) (UID, error) {
 	}
 	table := &archiverTable{}
 	for _, ti := range tinfo.Fields {
-		valueIndex, err := a.marshal(ti.Value(val))
+		fv := ti.Value(val)
+		if ti.OmitEmpty && isNil(fv) {
+			continue
+		}
+		a.path.pushKey(ti.Name)
+		valueIndex, err := a.marshal(fv)
+		a.path.pop()
 		if err != nil {
-			if err == errArchiverNilElem && ti.OmitEmpty {
+			if err == errArchiverNilElem && a.skipsNil() {
 				continue
 			}
 			return 0, err
@@ -532,8 +623,7 @@ func (a *Archiver) printDate(pval map[string]any) string {
 	if err := Dictionary(pval).Unmarshal(date); err != nil {
 		panic(err)
 	}
-	vt := time.Unix(int64(date.Time)+unixToCocoa, 0)
-	return fmt.Sprintf("time(%v)", vt)
+	return fmt.Sprintf("time(%v)", timeFromAppleSeconds(date.Time))
 }
 func (a *Archiver) printData(pval map[string]any) string {
 	data := &archiverData{}
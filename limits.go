@@ -0,0 +1,159 @@
+package plist
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// DecoderOptions holds resource limits for decoding untrusted property lists.
+// A limit that is zero (or negative) is not enforced.
+type DecoderOptions struct {
+	// MaxDepth is the maximum nesting depth of arrays and dictionaries.
+	MaxDepth int
+
+	// MaxObjects is the maximum number of values in a document, containers included.
+	// For binary property lists, this also bounds the size of the object table.
+	MaxObjects int
+
+	// MaxCollectionLength is the maximum number of entries in a single array or dictionary.
+	MaxCollectionLength int
+
+	// MaxBytes is the maximum combined size, in bytes, of all strings and data in a document.
+	// Binary and text property lists are read into memory whole, so it also bounds their size.
+	MaxBytes int64
+
+	// MaxExpandedObjects is the maximum number of Go values created while unmarshaling a document.
+	// Unlike MaxObjects, every reference to a shared binary object counts separately,
+	// which catches small binary documents that expand exponentially when decoded.
+	MaxExpandedObjects int
+
+	// PreserveSharing decodes repeated references to the same binary array or dictionary
+	// into the same Go slice or map rather than materializing a copy for each reference.
+	// Shared values are only counted once against MaxExpandedObjects.
+	PreserveSharing bool
+}
+
+// A LimitError is returned when a document exceeds one of the limits set in DecoderOptions.
+type LimitError struct {
+	// Limit is the name of the DecoderOptions field that was exceeded.
+	Limit string
+	// Max is the value of that field.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("plist: document exceeds %s (%d)", e.Limit, e.Max)
+}
+
+// limitTracker enforces DecoderOptions on behalf of a parser.
+// It also polls the decoder's context, as it is consulted for every value parsed.
+// A nil *limitTracker enforces nothing.
+type limitTracker struct {
+	opts   DecoderOptions
+	cancel cancellation
+
+	depth   int
+	objects int
+	bytes   int64
+}
+
+func newLimitTracker(opts DecoderOptions, cancel cancellation) *limitTracker {
+	return &limitTracker{opts: opts, cancel: cancel}
+}
+
+// tick records one step of parsing work.
+func (t *limitTracker) tick() {
+	if t == nil {
+		return
+	}
+	t.cancel.tick()
+}
+
+// reset forgets everything counted so far, for parsers that restart a document.
+func (t *limitTracker) reset() {
+	if t == nil {
+		return
+	}
+	t.depth, t.objects, t.bytes = 0, 0, 0
+}
+
+// enter records that the parser has descended into a collection.
+func (t *limitTracker) enter() {
+	if t == nil {
+		return
+	}
+	t.depth++
+	if t.opts.MaxDepth > 0 && t.depth > t.opts.MaxDepth {
+		panic(&LimitError{"MaxDepth", int64(t.opts.MaxDepth)})
+	}
+}
+
+// leave records that the parser has finished a collection.
+func (t *limitTracker) leave() {
+	if t == nil {
+		return
+	}
+	t.depth--
+}
+
+// checkLength validates the number of entries in a single collection.
+func (t *limitTracker) checkLength(n uint64) {
+	if t == nil {
+		return
+	}
+	if t.opts.MaxCollectionLength > 0 && n > uint64(t.opts.MaxCollectionLength) {
+		panic(&LimitError{"MaxCollectionLength", int64(t.opts.MaxCollectionLength)})
+	}
+}
+
+// checkObjects validates a declared object count before anything is allocated for it.
+func (t *limitTracker) checkObjects(n uint64) {
+	if t == nil {
+		return
+	}
+	if t.opts.MaxObjects > 0 && n > uint64(t.opts.MaxObjects) {
+		panic(&LimitError{"MaxObjects", int64(t.opts.MaxObjects)})
+	}
+}
+
+// countObject records one more value in the document.
+func (t *limitTracker) countObject() {
+	if t == nil {
+		return
+	}
+	t.objects++
+	t.checkObjects(uint64(t.objects))
+	t.cancel.tick()
+}
+
+// readAll reads a whole document from r, failing as soon as it grows past MaxBytes
+// rather than after an oversized input has been buffered.
+func (t *limitTracker) readAll(r io.Reader) []byte {
+	max := int64(0)
+	if t != nil {
+		max = t.opts.MaxBytes
+	}
+	if max > 0 && max < math.MaxInt64 {
+		r = io.LimitReader(r, max+1)
+	}
+	buffer, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	if max > 0 && int64(len(buffer)) > max {
+		panic(&LimitError{"MaxBytes", max})
+	}
+	return buffer
+}
+
+// countBytes records n more bytes of string or data content.
+func (t *limitTracker) countBytes(n uint64) {
+	if t == nil {
+		return
+	}
+	if t.opts.MaxBytes > 0 && n > uint64(t.opts.MaxBytes-t.bytes) {
+		panic(&LimitError{"MaxBytes", t.opts.MaxBytes})
+	}
+	t.bytes += int64(n)
+}
@@ -0,0 +1,206 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	value := map[string]any{
+		"nested": []any{[]any{[]any{"deep"}}},
+		"list":   []any{1, 2, 3, 4, 5, 6, 7, 8},
+		"blob":   []byte(strings.Repeat("x", 100)),
+	}
+
+	limitTests := []struct {
+		Name    string
+		Options DecoderOptions
+		Limit   string
+	}{
+		{"Depth", DecoderOptions{MaxDepth: 3}, "MaxDepth"},
+		{"Objects", DecoderOptions{MaxObjects: 10}, "MaxObjects"},
+		{"Collection Length", DecoderOptions{MaxCollectionLength: 5}, "MaxCollectionLength"},
+		{"Bytes", DecoderOptions{MaxBytes: 64}, "MaxBytes"},
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		doc, err := Marshal(value, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			for _, lt := range limitTests {
+				subtest(t, lt.Name, func(t *testing.T) {
+					decoder := NewDecoder(bytes.NewReader(doc))
+					decoder.SetOptions(lt.Options)
+					var val any
+					err := decoder.Decode(&val)
+
+					var limitErr *LimitError
+					if !errors.As(err, &limitErr) {
+						t.Fatalf("expected a LimitError, got %v", err)
+					}
+					if limitErr.Limit != lt.Limit {
+						t.Errorf("expected %s to trip, got %s", lt.Limit, limitErr.Limit)
+					}
+				})
+			}
+
+			subtest(t, "Generous Limits", func(t *testing.T) {
+				decoder := NewDecoder(bytes.NewReader(doc))
+				decoder.SetOptions(DecoderOptions{MaxDepth: 4, MaxObjects: 100, MaxCollectionLength: 8, MaxBytes: 1024})
+				var val any
+				if err := decoder.Decode(&val); err != nil {
+					t.Error(err)
+				}
+			})
+		})
+	}
+}
+
+func TestDecoderLimitsRejectHugeObjectTable(t *testing.T) {
+	// The trailer claims one million single-byte objects; the limit must trip before
+	// the object table is allocated.
+	doc := append([]byte("bplist00"), make([]byte, 1+1000000)...)
+	doc = append(doc,
+		0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00,
+		0x01,
+		0x04,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x42, 0x40,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+	)
+
+	decoder := NewDecoder(bytes.NewReader(doc))
+	decoder.SetOptions(DecoderOptions{MaxObjects: 1000})
+	var val any
+	var limitErr *LimitError
+	if err := decoder.Decode(&val); !errors.As(err, &limitErr) || limitErr.Limit != "MaxObjects" {
+		t.Errorf("expected MaxObjects to trip, got %v", err)
+	}
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestDecoderLimitsBoundInput(t *testing.T) {
+	const size = 1 << 20
+	for _, test := range []struct {
+		Name   string
+		Header string
+	}{
+		{"Binary", "bplist00"},
+		{"Text", "("},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			padding := bytes.Repeat([]byte{' '}, size)
+			input := &countingReader{r: io.MultiReader(strings.NewReader(test.Header), bytes.NewReader(padding))}
+			decoder := NewStreamDecoder(input)
+			decoder.SetOptions(DecoderOptions{MaxBytes: 1024})
+			var val any
+			var limitErr *LimitError
+			if err := decoder.Decode(&val); !errors.As(err, &limitErr) || limitErr.Limit != "MaxBytes" {
+				t.Errorf("expected MaxBytes to trip, got %v", err)
+			}
+			if input.n >= size {
+				t.Errorf("expected the input to be abandoned, but all %d bytes were read", input.n)
+			}
+		})
+	}
+
+	// A failed read is reported rather than parsed as a truncated document.
+	failure := errors.New("connection reset")
+	doc, err := Marshal([]any{"a"}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val any
+	input := io.MultiReader(bytes.NewReader(doc[:20]), iotest.ErrReader(failure))
+	if err := NewStreamDecoder(input).Decode(&val); !errors.Is(err, failure) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+// sharedArrayBplist returns a binary property list of depth nested arrays,
+// each of which refers to the previous array twice; it expands to 2^depth strings.
+func sharedArrayBplist(depth int) []byte {
+	doc := []byte("bplist00")
+	offsets := []byte{byte(len(doc))}
+	doc = append(doc, 0x51, 'x')
+	for i := 1; i <= depth; i++ {
+		offsets = append(offsets, byte(len(doc)))
+		doc = append(doc, 0xA2, byte(i-1), byte(i-1))
+	}
+	offtable := len(doc)
+	doc = append(doc, offsets...)
+	return append(doc,
+		0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00,
+		0x01,
+		0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(depth+1),
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(depth),
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(offtable),
+	)
+}
+
+func TestDecoderLimitsExpandedObjects(t *testing.T) {
+	doc := sharedArrayBplist(30)
+
+	decoder := NewDecoder(bytes.NewReader(doc))
+	decoder.SetOptions(DecoderOptions{MaxObjects: 100, MaxExpandedObjects: 10000})
+	var val any
+	var limitErr *LimitError
+	if err := decoder.Decode(&val); !errors.As(err, &limitErr) || limitErr.Limit != "MaxExpandedObjects" {
+		t.Errorf("expected MaxExpandedObjects to trip, got %v", err)
+	}
+
+	var typed [][][]any
+	decoder = NewDecoder(bytes.NewReader(doc))
+	decoder.SetOptions(DecoderOptions{MaxExpandedObjects: 10000})
+	if err := decoder.Decode(&typed); !errors.As(err, &limitErr) || limitErr.Limit != "MaxExpandedObjects" {
+		t.Errorf("expected MaxExpandedObjects to trip for a typed value, got %v", err)
+	}
+}
+
+func TestDecoderPreserveSharing(t *testing.T) {
+	doc := sharedArrayBplist(30)
+
+	decoder := NewDecoder(bytes.NewReader(doc))
+	decoder.SetOptions(DecoderOptions{MaxExpandedObjects: 100, PreserveSharing: true})
+	var val any
+	if err := decoder.Decode(&val); err != nil {
+		t.Fatal(err)
+	}
+
+	top := val.([]any)
+	first, second := top[0].([]any), top[1].([]any)
+	if &first[0] != &second[0] {
+		t.Error("expected both references to the shared array to decode into the same slice")
+	}
+
+	var typed [][]any
+	decoder = NewDecoder(bytes.NewReader(sharedArrayBplist(2)))
+	decoder.SetOptions(DecoderOptions{PreserveSharing: true})
+	if err := decoder.Decode(&typed); err != nil {
+		t.Fatal(err)
+	}
+	if &typed[0][0] != &typed[1][0] {
+		t.Error("expected both references to the shared array to decode into the same typed slice")
+	}
+}
@@ -0,0 +1,112 @@
+package plist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type keyColor int
+
+func (c keyColor) MarshalText() ([]byte, error) {
+	return []byte([]string{"red", "green"}[c]), nil
+}
+
+func (c *keyColor) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "red":
+		*c = 0
+	case "green":
+		*c = 1
+	default:
+		return &incompatibleDecodeTypeError{reflect.TypeOf(c).Elem(), string(text)}
+	}
+	return nil
+}
+
+func TestMapKeys(t *testing.T) {
+	device := UUID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+	for _, test := range []struct {
+		Name  string
+		Value any
+		Keys  []string
+	}{
+		{"Int", map[int]string{-1: "a", 2: "b"}, []string{"-1", "2"}},
+		{"Uint8", map[uint8]bool{255: true}, []string{"255"}},
+		{"Bool", map[bool]int{true: 1, false: 0}, []string{"false", "true"}},
+		{"TextMarshaler", map[keyColor]int{0: 1, 1: 2}, []string{"green", "red"}},
+		{"UUID", map[UUID]string{device: "phone"}, []string{device.String()}},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat} {
+				var buf strings.Builder
+				enc := NewEncoderForFormat(&buf, format)
+				enc.PreserveKeyOrder()
+				if err := enc.Encode(test.Value); err != nil {
+					t.Fatal(err)
+				}
+
+				var generic OrderedDictionary
+				if _, err := Unmarshal([]byte(buf.String()), &generic); err != nil {
+					t.Fatal(err)
+				}
+				keys := make([]string, len(generic))
+				for i, e := range generic {
+					keys[i] = e.Key
+				}
+				if !reflect.DeepEqual(keys, test.Keys) {
+					t.Errorf("%s: expected keys %v, got %v", FormatNames[format], test.Keys, keys)
+				}
+
+				got := reflect.New(reflect.TypeOf(test.Value))
+				if _, err := Unmarshal([]byte(buf.String()), got.Interface()); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got.Elem().Interface(), test.Value) {
+					t.Errorf("%s: expected %v, got %v", FormatNames[format], test.Value, got.Elem())
+				}
+			}
+		})
+	}
+}
+
+func TestInterfaceMapKeys(t *testing.T) {
+	for _, test := range []struct {
+		Name     string
+		Into     any
+		Expected any
+	}{
+		{"Strings", new(map[any]string), map[any]string{"a": "b"}},
+		{"Any", new(map[any]any), map[any]any{"a": "b"}},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte("{a = b;}"), test.Into); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(test.Into).Elem().Interface(); !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("expected %v, got %v", test.Expected, got)
+			}
+		})
+	}
+}
+
+func TestIllegalMapKeys(t *testing.T) {
+	for _, test := range []struct {
+		Name string
+		Doc  string
+		Into any
+	}{
+		{"Not an integer", "<plist><dict><key>one</key><string>a</string></dict></plist>", new(map[int]string)},
+		{"Overflow", "<plist><dict><key>256</key><string>a</string></dict></plist>", new(map[uint8]string)},
+		{"Negative unsigned", "<plist><dict><key>-1</key><string>a</string></dict></plist>", new(map[uint]string)},
+		{"Not a boolean", "<plist><dict><key>maybe</key><string>a</string></dict></plist>", new(map[bool]string)},
+		{"Rejected by UnmarshalText", "<plist><dict><key>blue</key><string>a</string></dict></plist>", new(map[keyColor]string)},
+		{"Unsupported key type", "<plist><dict><key>1.5</key><string>a</string></dict></plist>", new(map[float64]string)},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte(test.Doc), test.Into); err == nil {
+				t.Errorf("expected an error, got %v", reflect.ValueOf(test.Into).Elem())
+			}
+		})
+	}
+}
@@ -12,10 +12,23 @@ type Dictionary map[string]any
 
 // Unmarshal 序列化
 func (m Dictionary) Unmarshal(v any) error {
-	return m.unmarshal(map[string]any(m), reflect.ValueOf(v))
+	d := &dictionaryDecoder{}
+	if err := d.unmarshal(map[string]any(m), reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	if len(d.missing) > 0 {
+		return &MissingKeyError{Paths: d.missing}
+	}
+	return nil
 }
 
-func (m Dictionary) unmarshal(v any, val reflect.Value) error {
+// dictionaryDecoder holds the state of a single Dictionary.Unmarshal call.
+type dictionaryDecoder struct {
+	path    keyPath
+	missing []string // paths of required keys that were absent
+}
+
+func (m *dictionaryDecoder) unmarshal(v any, val reflect.Value) error {
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			val.Set(reflect.New(val.Type().Elem()))
@@ -150,17 +163,19 @@ func (m Dictionary) unmarshal(v any, val reflect.Value) error {
 	}
 	return nil
 }
-func (m Dictionary) unmarshalSlice(array []any, val reflect.Value) error {
+func (m *dictionaryDecoder) unmarshalSlice(array []any, val reflect.Value) error {
 	new := reflect.MakeSlice(val.Type(), len(array), len(array))
 	val.Set(new)
 	for i, v := range array {
+		m.path.pushIndex(i)
 		if err := m.unmarshal(v, val.Index(i)); err != nil {
 			return err
 		}
+		m.path.pop()
 	}
 	return nil
 }
-func (m Dictionary) unmarshalStruct(dict map[string]any, val reflect.Value) error {
+func (m *dictionaryDecoder) unmarshalStruct(dict map[string]any, val reflect.Value) error {
 	typ := val.Type()
 	tinfo, err := GetTypeInfo(typ)
 	if err != nil {
@@ -168,11 +183,17 @@ func (m Dictionary) unmarshalStruct(dict map[string]any, val reflect.Value) erro
 	}
 	for _, finfo := range tinfo.Fields {
 		if dval, ok := dict[finfo.Name]; ok {
+			m.path.pushKey(finfo.Name)
 			if err := m.unmarshal(dval, finfo.Value(val)); err != nil {
 				return err
 			}
-		} else if !finfo.OmitEmpty {
-			//return fmt.Errorf("field[%s] can not empty", finfo.name)
+			m.path.pop()
+		} else if finfo.Required {
+			m.missing = append(m.missing, m.path.child(finfo.Name))
+		} else if finfo.HasDefault {
+			if err := unmarshalDefault(&finfo, finfo.Value(val)); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -2,7 +2,12 @@ package plist
 
 import (
 	"encoding"
+	"fmt"
+	"math/big"
+	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -29,6 +34,12 @@ var (
 	plistMarshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
 	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 	timeType           = reflect.TypeOf((*time.Time)(nil)).Elem()
+	urlType            = reflect.TypeOf((*url.URL)(nil)).Elem()
+	uuidType           = reflect.TypeOf((*UUID)(nil)).Elem()
+	setType            = reflect.TypeOf((*Set)(nil)).Elem()
+	orderedSetType     = reflect.TypeOf((*OrderedSet)(nil)).Elem()
+	int128Type         = reflect.TypeOf((*Int128)(nil)).Elem()
+	bigIntType         = reflect.TypeOf((*big.Int)(nil)).Elem()
 )
 
 func implementsInterface(val reflect.Value, interfaceType reflect.Type) (any, bool) {
@@ -69,7 +80,10 @@ func (p *Encoder) marshalTextInterface(marshalable encoding.TextMarshaler) cfVal
 
 // marshalStruct marshals a reflected struct value to a plist dictionary
 func (p *Encoder) marshalStruct(typ reflect.Type, val reflect.Value) cfValue {
-	tinfo, _ := GetTypeInfo(val.Type())
+	tinfo, err := GetTypeInfo(val.Type())
+	if err != nil {
+		panic(err)
+	}
 	dict := &cfDictionary{
 		keys:   make([]string, 0, len(tinfo.Fields)),
 		values: make([]cfValue, 0, len(tinfo.Fields)),
@@ -79,23 +93,87 @@ func (p *Encoder) marshalStruct(typ reflect.Type, val reflect.Value) cfValue {
 		if !value.IsValid() || (finfo.OmitEmpty && IsEmptyValue(value)) {
 			continue
 		}
-		dict.keys = append(dict.keys, finfo.Name)
-		dict.values = append(dict.values, p.marshal(value))
+		p.path.pushKey(finfo.Name)
+		if subpval := p.marshalElement(value); subpval != nil {
+			dict.keys = append(dict.keys, finfo.Name)
+			dict.values = append(dict.values, subpval)
+		}
+		p.path.pop()
+	}
+	if tinfo.Remain != nil {
+		p.marshalRemain(dict, tinfo.Remain.Value(val))
 	}
 	return dict
 }
 
+// marshalRemain merges the entries of a struct's remain map into dict.
+// Keys that belong to one of the struct's own fields are left alone.
+func (p *Encoder) marshalRemain(dict *cfDictionary, remain reflect.Value) {
+	if remain.Len() == 0 {
+		return
+	}
+	known := make(map[string]bool, len(dict.keys))
+	for _, k := range dict.keys {
+		known[k] = true
+	}
+	keys := remain.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, keyv := range keys {
+		if known[keyv.String()] {
+			continue
+		}
+		p.path.pushKey(keyv.String())
+		if subpval := p.marshalElement(remain.MapIndex(keyv)); subpval != nil {
+			dict.keys = append(dict.keys, keyv.String())
+			dict.values = append(dict.values, subpval)
+		}
+		p.path.pop()
+	}
+}
+
+func (p *Encoder) marshalOrderedDictionary(od OrderedDictionary) cfValue {
+	dict := &cfDictionary{
+		keys:   make([]string, 0, len(od)),
+		values: make([]cfValue, 0, len(od)),
+	}
+	for _, e := range od {
+		p.path.pushKey(e.Key)
+		if subpval := p.marshalElement(reflect.ValueOf(e.Value)); subpval != nil {
+			dict.keys = append(dict.keys, e.Key)
+			dict.values = append(dict.values, subpval)
+		}
+		p.path.pop()
+	}
+	return dict
+}
+
+// marshalElement marshals a value held by an array, dictionary or struct field. If the value
+// is nil, the nil policy decides what to do with it; it is left out if marshalElement returns nil.
+func (p *Encoder) marshalElement(val reflect.Value) cfValue {
+	if pval := p.marshal(val); pval != nil {
+		return pval
+	}
+	switch p.nilPolicy {
+	case NilError:
+		panic(&NilValueError{Path: p.path.String()})
+	case NilPlaceholder:
+		return cfNull{}
+	}
+	return nil
+}
+
 func (p *Encoder) marshal(val reflect.Value) cfValue {
 	if !val.IsValid() {
 		return nil
 	}
+	p.cancel.tick()
 	// interface, map, pointer, or slice
 	// Descend into pointers or interfaces
 	if val.Kind() == reflect.Ptr || (val.Kind() == reflect.Interface && val.NumMethod() == 0) {
 		valelem := val.Elem()
-		if !valelem.IsValid() {
+		if !valelem.IsValid() && val.Kind() == reflect.Ptr {
 			typelem := val.Type().Elem()
-			if typelem.Kind() == reflect.Struct {
+			if typelem.Kind() == reflect.Struct && !hasOwnEncoding(typelem) && p.nilPolicy == NilSkip {
 				return &cfDictionary{}
 			}
 		}
@@ -107,6 +185,26 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 		time := val.Interface().(time.Time)
 		return cfDate(time)
 	}
+	// UUID implements TextMarshaler, but binary property lists can store it natively
+	if typ == uuidType {
+		return cfUUID(val.Interface().(UUID))
+	}
+	if typ == urlType {
+		u := val.Interface().(url.URL)
+		return cfURL(u.String())
+	}
+	if typ == int128Type {
+		return newInt128Number(val.Interface().(Int128))
+	}
+	// big.Int implements TextMarshaler, but it is an integer
+	if typ == bigIntType {
+		b := val.Interface().(big.Int)
+		i, ok := Int128FromBig(&b)
+		if !ok {
+			panic(fmt.Errorf("plist: integer %v does not fit in 128 bits", &b))
+		}
+		return newInt128Number(i)
+	}
 	if receiver, can := implementsInterface(val, plistMarshalerType); can {
 		return p.marshalPlistInterface(receiver.(Marshaler))
 	}
@@ -117,6 +215,30 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 	if typ == uidType {
 		return cfUID(val.Uint())
 	}
+	if typ == rawValueType {
+		return val.Interface().(RawValue).pval
+	}
+	if typ == valueType {
+		return val.Interface().(Value).pval
+	}
+	if typ == orderedDictionaryType {
+		return p.marshalOrderedDictionary(val.Interface().(OrderedDictionary))
+	}
+	if typ == setType || typ == orderedSetType {
+		arr := &cfArray{values: make([]cfValue, 0, val.Len())}
+		for i := 0; i < val.Len(); i++ {
+			p.path.pushIndex(i)
+			if subpval := p.marshalElement(val.Index(i)); subpval != nil {
+				arr.values = append(arr.values, subpval)
+			}
+			p.path.pop()
+		}
+		arr.set = cfUnorderedSet
+		if typ == orderedSetType {
+			arr.set = cfOrderedSet
+		}
+		return arr
+	}
 	if val.Kind() == reflect.Struct {
 		return p.marshalStruct(typ, val)
 	}
@@ -146,16 +268,18 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 			}
 			return cfData(bytes)
 		} else {
-			values := make([]cfValue, val.Len())
+			values := make([]cfValue, 0, val.Len())
 			for i, length := 0, val.Len(); i < length; i++ {
-				if subpval := p.marshal(val.Index(i)); subpval != nil {
-					values[i] = subpval
+				p.path.pushIndex(i)
+				if subpval := p.marshalElement(val.Index(i)); subpval != nil {
+					values = append(values, subpval)
 				}
+				p.path.pop()
 			}
-			return &cfArray{values}
+			return &cfArray{values: values}
 		}
 	case reflect.Map:
-		if typ.Key().Kind() != reflect.String {
+		if !isMapKeyType(typ.Key()) {
 			panic(&unknownTypeError{typ})
 		}
 		l := val.Len()
@@ -163,14 +287,89 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 			keys:   make([]string, 0, l),
 			values: make([]cfValue, 0, l),
 		}
-		for _, keyv := range val.MapKeys() {
-			if subpval := p.marshal(val.MapIndex(keyv)); subpval != nil {
-				dict.keys = append(dict.keys, keyv.String())
+		keys := val.MapKeys()
+		names := make([]string, len(keys))
+		for i, keyv := range keys {
+			names[i] = mapKeyString(keyv)
+		}
+		if p.preserveKeyOrder {
+			// Go maps have no order of their own; sort them so the output is stable.
+			sort.Sort(mapKeysByName{keys, names})
+		}
+		for i, keyv := range keys {
+			p.path.pushKey(names[i])
+			if subpval := p.marshalElement(val.MapIndex(keyv)); subpval != nil {
+				dict.keys = append(dict.keys, names[i])
 				dict.values = append(dict.values, subpval)
 			}
+			p.path.pop()
 		}
 		return dict
 	default:
 		panic(&unknownTypeError{typ})
 	}
 }
+
+// hasOwnEncoding reports whether values of the struct type typ are encoded as something
+// other than a dictionary of their fields, so a nil pointer to one is an absent value.
+func hasOwnEncoding(typ reflect.Type) bool {
+	switch typ {
+	case timeType, urlType, bigIntType, int128Type, rawValueType, valueType:
+		return true
+	}
+	ptr := reflect.PointerTo(typ)
+	return ptr.Implements(plistMarshalerType) || ptr.Implements(textMarshalerType)
+}
+
+// isMapKeyType reports whether maps with keys of type typ can be encoded. As with encoding/json,
+// keys are strings, integers or encoding.TextMarshalers; booleans are accepted as well.
+func isMapKeyType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Bool:
+		return true
+	}
+	return typ.Implements(textMarshalerType)
+}
+
+// mapKeyString returns the dictionary key for a map key of a type accepted by isMapKeyType.
+// A key of string kind is used as it is, even if it is a TextMarshaler.
+func mapKeyString(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	if tm, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return ""
+		}
+		text, err := tm.MarshalText()
+		if err != nil {
+			panic(err)
+		}
+		return string(text)
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(key.Bool())
+	}
+	panic(&unknownTypeError{key.Type()})
+}
+
+// mapKeysByName sorts map keys by their dictionary keys.
+type mapKeysByName struct {
+	keys  []reflect.Value
+	names []string
+}
+
+func (m mapKeysByName) Len() int           { return len(m.keys) }
+func (m mapKeysByName) Less(i, j int) bool { return m.names[i] < m.names[j] }
+func (m mapKeysByName) Swap(i, j int) {
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+	m.names[i], m.names[j] = m.names[j], m.names[i]
+}
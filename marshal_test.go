@@ -56,7 +56,7 @@ func TestInvalidMarshal(t *testing.T) {
 	}{
 		{"Function", func() {}},
 		{"Nil", nil},
-		{"Map with integer keys", map[int]string{1: "hi"}},
+		{"Map with floating-point keys", map[float64]string{1: "hi"}},
 		{"Channel", make(chan int)},
 	}
 
@@ -0,0 +1,74 @@
+package plist
+
+// An ArrayMergeStrategy determines how Merge combines two arrays at the same path.
+type ArrayMergeStrategy int
+
+const (
+	// ArrayReplace uses the overlay's array in place of the base's.
+	ArrayReplace ArrayMergeStrategy = iota
+	// ArrayAppend appends the overlay's elements to the base's.
+	ArrayAppend
+	// ArrayUnion appends the overlay's elements that are not equal to any of the base's.
+	ArrayUnion
+)
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	Arrays ArrayMergeStrategy
+}
+
+// Merge returns a deep merge of overlay onto base, leaving both unchanged.
+//
+// Dictionaries are merged key by key, and arrays according to opts.Arrays.
+// Anywhere else, including where base and overlay hold different kinds of value,
+// the overlay's value replaces the base's. An invalid overlay leaves base as it is.
+func Merge(base, overlay Value, opts MergeOptions) Value {
+	return Value{mergeValues(base.pval, overlay.pval, opts)}
+}
+
+func mergeValues(base, overlay cfValue, opts MergeOptions) cfValue {
+	if overlay == nil {
+		return cloneValue(base, nil)
+	}
+
+	switch overlay := overlay.(type) {
+	case *cfDictionary:
+		base, ok := base.(*cfDictionary)
+		if !ok {
+			break
+		}
+		merged := cloneValue(base, nil).(*cfDictionary)
+		for i, k := range overlay.keys {
+			if j := merged.index(k); j >= 0 {
+				merged.values[j] = mergeValues(merged.values[j], overlay.values[i], opts)
+			} else {
+				merged.keys = append(merged.keys, k)
+				merged.values = append(merged.values, cloneValue(overlay.values[i], nil))
+			}
+		}
+		return merged
+	case *cfArray:
+		base, ok := base.(*cfArray)
+		if !ok || opts.Arrays == ArrayReplace {
+			break
+		}
+		merged := cloneValue(base, nil).(*cfArray)
+		for _, subv := range overlay.values {
+			if opts.Arrays == ArrayUnion && containsValue(merged.values, subv) {
+				continue
+			}
+			merged.values = append(merged.values, cloneValue(subv, nil))
+		}
+		return merged
+	}
+	return cloneValue(overlay, nil)
+}
+
+func containsValue(values []cfValue, pval cfValue) bool {
+	for _, v := range values {
+		if equalValues(v, pval) {
+			return true
+		}
+	}
+	return false
+}
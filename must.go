@@ -1,7 +1,10 @@
 package plist
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 )
 
@@ -33,6 +36,40 @@ func mustParseUint(str string, base, bits int) uint64 {
 	return i
 }
 
+// mustParseInteger parses an integer, which may be negative, of up to 128 bits.
+func mustParseInteger(str string, base int) *cfNumber {
+	var err error
+	if len(str) > 0 && str[0] == '-' {
+		var i int64
+		if i, err = strconv.ParseInt(str, base, 64); err == nil {
+			return &cfNumber{signed: true, value: uint64(i)}
+		}
+	} else {
+		var u uint64
+		if u, err = strconv.ParseUint(str, base, 64); err == nil {
+			return &cfNumber{signed: false, value: u}
+		}
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		panic(err)
+	}
+
+	b, _ := new(big.Int).SetString(str, base)
+	i, ok := Int128FromBig(b)
+	if !ok {
+		panic(fmt.Errorf("integer %s does not fit in 128 bits", str))
+	}
+	return newInt128Number(i)
+}
+
+func mustParseReal(str string, preserveFloat32 bool) *cfReal {
+	r, err := parseReal(str, preserveFloat32)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
 func mustParseFloat(str string, bits int) float64 {
 	i, err := strconv.ParseFloat(str, bits)
 	if err != nil {
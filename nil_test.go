@@ -0,0 +1,219 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type nilHolder struct {
+	List     []*string
+	Optional *string `plist:",omitempty"`
+	Required *string
+}
+
+func encodeWithNilPolicy(v any, format int, policy NilPolicy) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, format)
+	enc.SetNilPolicy(policy)
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+func TestNilPolicySkip(t *testing.T) {
+	a, b := "a", "b"
+	value := nilHolder{List: []*string{&a, nil, &b}}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := encodeWithNilPolicy(value, format, NilSkip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if _, err := Unmarshal(doc, &got); err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]any{"List": []any{"a", "b"}}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %#v, got %#v", expected, got)
+			}
+		})
+	}
+}
+
+func TestNilPolicySkipTypesWithOwnEncoding(t *testing.T) {
+	for _, test := range []struct {
+		Name  string
+		Value any
+	}{
+		{"Int128", struct{ P *Int128 }{}},
+		{"big.Int", struct{ P *big.Int }{}},
+		{"Value", struct{ P *Value }{}},
+		{"RawValue", struct{ P *RawValue }{}},
+		{"url.URL", struct{ P *url.URL }{}},
+		{"time.Time", struct{ P *time.Time }{}},
+		{"TextMarshaler", struct{ P *textMarshalerStruct }{}},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			for _, format := range []int{BinaryFormat, XMLFormat} {
+				doc, err := encodeWithNilPolicy(test.Value, format, NilSkip)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got map[string]any
+				if _, err := Unmarshal(doc, &got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != 0 {
+					t.Errorf("%s: expected the nil pointer to be left out, got %#v", FormatNames[format], got)
+				}
+			}
+		})
+	}
+
+	// A nil pointer to any other struct is still written as an empty dictionary.
+	doc, err := encodeWithNilPolicy(struct{ P *nilHolder }{}, XMLFormat, NilSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if _, err := Unmarshal(doc, &got); err != nil || !reflect.DeepEqual(got, map[string]any{"P": map[string]any{}}) {
+		t.Errorf("expected an empty dictionary, got %#v (%v)", got, err)
+	}
+}
+
+// textMarshalerStruct is a struct that encodes itself as text.
+type textMarshalerStruct struct {
+	Text string
+}
+
+func (s textMarshalerStruct) MarshalText() ([]byte, error) {
+	return []byte(s.Text), nil
+}
+
+func TestNilPolicyError(t *testing.T) {
+	a := "a"
+	for _, test := range []struct {
+		Name  string
+		Value any
+		Path  string
+	}{
+		{"Array", nilHolder{List: []*string{&a, nil}, Required: &a}, "List[1]"},
+		{"Field", nilHolder{List: []*string{&a}}, "Required"},
+		{"Map", map[string]any{"Outer": map[string]any{"Inner": nil}}, "Outer.Inner"},
+	} {
+		subtest(t, test.Name, func(t *testing.T) {
+			_, err := encodeWithNilPolicy(test.Value, XMLFormat, NilError)
+			var nilErr *NilValueError
+			if !errors.As(err, &nilErr) {
+				t.Fatalf("expected a *NilValueError, got %v", err)
+			}
+			if nilErr.Path != test.Path {
+				t.Errorf("expected path %q, got %q", test.Path, nilErr.Path)
+			}
+		})
+	}
+
+	// Leaving out an omitempty field is not an error.
+	if _, err := encodeWithNilPolicy(nilHolder{Required: &a}, XMLFormat, NilError); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestNilPolicyPlaceholder(t *testing.T) {
+	a := "a"
+	value := nilHolder{List: []*string{nil, &a}}
+
+	subtest(t, "Binary", func(t *testing.T) {
+		doc, err := encodeWithNilPolicy(value, BinaryFormat, NilPlaceholder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]any{"List": []any{nil, "a"}, "Required": nil}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %#v, got %#v", expected, got)
+		}
+	})
+
+	subtest(t, "XML", func(t *testing.T) {
+		doc, err := encodeWithNilPolicy(value, XMLFormat, NilPlaceholder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if _, err := Unmarshal(doc, &got); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]any{"List": []any{"", "a"}, "Required": ""}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %#v, got %#v", expected, got)
+		}
+
+		// An empty string is an empty string, even where a nil would fit.
+		var typed nilHolder
+		if _, err := Unmarshal(doc, &typed); err != nil {
+			t.Fatal(err)
+		}
+		if typed.Required == nil || *typed.Required != "" || typed.List[0] == nil || *typed.List[0] != "" {
+			t.Errorf("expected empty strings, got %#v", typed)
+		}
+	})
+
+	subtest(t, "OpenStep", func(t *testing.T) {
+		doc, err := encodeWithNilPolicy(value, OpenStepFormat, NilPlaceholder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := `{List=("",a,);Required="";}`; string(doc) != expected {
+			t.Errorf("expected %s, got %s", expected, doc)
+		}
+	})
+}
+
+func TestArchiverNilPolicy(t *testing.T) {
+	type holder struct {
+		Name     string  `plist:"Name"`
+		Nickname *string `plist:"Nickname"`
+	}
+	value := holder{Name: "a"}
+
+	if _, err := (&Archiver{}).Marshal(&value); err == nil {
+		t.Error("expected an error for a nil field without a nil policy")
+	}
+
+	archive := &Archiver{}
+	archive.SetNilPolicy(NilError)
+	_, err := archive.Marshal(&value)
+	var nilErr *NilValueError
+	if !errors.As(err, &nilErr) || nilErr.Path != "Nickname" {
+		t.Errorf("expected a *NilValueError at Nickname, got %v", err)
+	}
+
+	archive = &Archiver{}
+	archive.SetNilPolicy(NilPlaceholder)
+	doc, err := archive.Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Archiver{}
+	if err := decoded.ReadFromData(doc); err != nil {
+		t.Fatal(err)
+	}
+	nickname := "stale"
+	got := holder{Nickname: &nickname}
+	if err := decoded.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" || got.Nickname != nil {
+		t.Errorf("expected the $null object to decode as nil, got %#v", got)
+	}
+}
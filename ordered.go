@@ -0,0 +1,62 @@
+package plist
+
+import (
+	"reflect"
+)
+
+// An OrderedDictionary is a dictionary that remembers the order of its keys,
+// for use where map[string]any would lose it.
+//
+// A Decoder fills an OrderedDictionary in document order, and decodes any
+// dictionaries nested within it as OrderedDictionaries too. An Encoder set to
+// PreserveKeyOrder writes its entries in order.
+type OrderedDictionary []DictionaryEntry
+
+// A DictionaryEntry is a single key and value in an OrderedDictionary.
+type DictionaryEntry struct {
+	Key   string
+	Value any
+}
+
+var orderedDictionaryType = reflect.TypeOf(OrderedDictionary(nil))
+
+// Keys returns the dictionary's keys in order.
+func (d OrderedDictionary) Keys() []string {
+	keys := make([]string, len(d))
+	for i, e := range d {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+// Get returns the value stored under key.
+func (d OrderedDictionary) Get(key string) (any, bool) {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set stores value under key, adding key after the existing keys if it is new.
+func (d *OrderedDictionary) Set(key string, value any) {
+	for i, e := range *d {
+		if e.Key == key {
+			(*d)[i].Value = value
+			return
+		}
+	}
+	*d = append(*d, DictionaryEntry{key, value})
+}
+
+// Delete removes key, and reports whether it was present.
+func (d *OrderedDictionary) Delete(key string) bool {
+	for i, e := range *d {
+		if e.Key == key {
+			*d = append((*d)[:i], (*d)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrderedDictionaryRoundTrip(t *testing.T) {
+	doc := []byte(`<plist><dict>
+		<key>zebra</key><integer>1</integer>
+		<key>apple</key><dict><key>y</key><true/><key>x</key><false/></dict>
+		<key>mango</key><array><dict><key>b</key><string/><key>a</key><string/></dict></array>
+	</dict></plist>`)
+
+	var od OrderedDictionary
+	if _, err := Unmarshal(doc, &od); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOrder := func(t *testing.T, od OrderedDictionary) {
+		t.Helper()
+		if keys := od.Keys(); !reflect.DeepEqual(keys, []string{"zebra", "apple", "mango"}) {
+			t.Errorf("unexpected top-level key order %v", keys)
+		}
+		apple, _ := od.Get("apple")
+		if nested, ok := apple.(OrderedDictionary); !ok || !reflect.DeepEqual(nested.Keys(), []string{"y", "x"}) {
+			t.Errorf("expected nested dictionary to keep its order, got %#v", apple)
+		}
+		mango, _ := od.Get("mango")
+		if list, ok := mango.([]any); !ok || !reflect.DeepEqual(list[0].(OrderedDictionary).Keys(), []string{"b", "a"}) {
+			t.Errorf("expected dictionary inside array to keep its order, got %#v", mango)
+		}
+	}
+	assertOrder(t, od)
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoderForFormat(&buf, format)
+			encoder.PreserveKeyOrder()
+			if err := encoder.Encode(od); err != nil {
+				t.Fatal(err)
+			}
+
+			var val any
+			decoder := NewDecoder(bytes.NewReader(buf.Bytes()))
+			decoder.UseOrderedDictionaries()
+			if err := decoder.Decode(&val); err != nil {
+				t.Fatal(err)
+			}
+			assertOrder(t, val.(OrderedDictionary))
+		})
+	}
+}
+
+func TestEncoderPreserveKeyOrder(t *testing.T) {
+	type info struct {
+		Name    string            `plist:"CFBundleName"`
+		Version string            `plist:"CFBundleVersion"`
+		Extra   map[string]string `plist:"Extra"`
+	}
+	value := info{"Example", "1", map[string]string{"b": "2", "a": "1", "c": "3"}}
+
+	keyOrder := func(doc []byte) []string {
+		var keys []string
+		for _, line := range strings.Split(string(doc), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "<key>") {
+				keys = append(keys, strings.TrimSuffix(strings.TrimPrefix(line, "<key>"), "</key>"))
+			}
+		}
+		return keys
+	}
+
+	var sorted, ordered bytes.Buffer
+	if err := NewEncoder(&sorted).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewEncoder(&ordered)
+	encoder.PreserveKeyOrder()
+	if err := encoder.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"CFBundleName", "CFBundleVersion", "Extra", "a", "b", "c"}
+	if keys := keyOrder(ordered.Bytes()); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected field order %v, got %v", expected, keys)
+	}
+	if keys := keyOrder(sorted.Bytes()); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected sorted order %v, got %v", expected, keys)
+	}
+
+	reversed := struct {
+		Z int
+		A int
+	}{}
+	ordered.Reset()
+	encoder = NewEncoder(&ordered)
+	encoder.PreserveKeyOrder()
+	if err := encoder.Encode(reversed); err != nil {
+		t.Fatal(err)
+	}
+	if keys := keyOrder(ordered.Bytes()); !reflect.DeepEqual(keys, []string{"Z", "A"}) {
+		t.Errorf("expected declaration order [Z A], got %v", keys)
+	}
+}
+
+func TestEncodeDoesNotReorderValue(t *testing.T) {
+	root := NewDictionary()
+	root.Set("zebra", NewInt(1))
+	root.Set("apple", NewInt(2))
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat} {
+		if _, err := Marshal(root, format); err != nil {
+			t.Fatal(err)
+		}
+		if keys := root.Keys(); !reflect.DeepEqual(keys, []string{"zebra", "apple"}) {
+			t.Errorf("%s: expected encoding to leave the Value's order alone, got %v", FormatNames[format], keys)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package plist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Operations supported in a Patch, named as in JSON Patch (RFC 6902).
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchTest    = "test"
+)
+
+// ErrTestFailed is reported (wrapped in a *PatchError) when a test operation does not match.
+var ErrTestFailed = errors.New("test failed")
+
+// A PatchOp is a single operation in a Patch. Paths may use any notation accepted by ParsePath.
+type PatchOp struct {
+	Op    string `plist:"op" json:"op"`
+	Path  string `plist:"path" json:"path"`
+	From  string `plist:"from,omitempty" json:"from,omitempty"`
+	Value Value  `plist:"value,omitempty" json:"value,omitempty"`
+}
+
+// MarshalJSON encodes op as a JSON Patch operation. As Value has a MarshalJSON method of its
+// own, omitempty cannot leave it out, so an operation without a Value has no "value" member here.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		From  string `json:"from,omitempty"`
+		Value *Value `json:"value,omitempty"`
+	}{Op: op.Op, Path: op.Path, From: op.From}
+	if op.Value.IsValid() {
+		out.Value = &op.Value
+	}
+	return json.Marshal(out)
+}
+
+// A Patch is a list of operations to apply to a property list, in the manner of JSON Patch (RFC 6902):
+//
+//	add      adds Value at Path, replacing a dictionary entry or inserting into an array
+//	remove   removes the value at Path
+//	replace  replaces the value at Path, which must exist
+//	move     removes the value at From and adds it at Path
+//	test     checks that the value at Path is equal to Value (see Diff)
+//
+// A Patch can be encoded and decoded as a property list (an array of dictionaries) or as JSON.
+type Patch []PatchOp
+
+// A PatchError records the operation of a Patch that could not be applied.
+type PatchError struct {
+	Index int // position of the operation in the Patch
+	Op    PatchOp
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("plist: patch operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies the patch to a copy of doc and returns the result.
+// If any operation fails, Apply returns an error and doc is left as it was.
+func (p Patch) Apply(doc Value) (Value, error) {
+	doc = doc.Clone()
+	for i, op := range p {
+		var err error
+		doc, err = op.apply(doc)
+		if err != nil {
+			return Value{}, &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+func (op PatchOp) apply(doc Value) (Value, error) {
+	path, err := ParsePath(op.Path)
+	if err != nil {
+		return doc, err
+	}
+
+	switch op.Op {
+	case PatchAdd, PatchReplace:
+		if !op.Value.IsValid() {
+			return doc, errors.New("missing value")
+		}
+		val := op.Value.Clone()
+		if len(path) == 0 {
+			return val, nil
+		}
+		if op.Op == PatchReplace {
+			if _, err := path.Get(doc); err != nil {
+				return doc, err
+			}
+			return doc, path.Set(doc, val)
+		}
+		return doc, path.add(doc, val)
+	case PatchRemove:
+		return doc, path.Delete(doc)
+	case PatchMove:
+		from, err := ParsePath(op.From)
+		if err != nil {
+			return doc, err
+		}
+		if len(from) < len(path) && from.isPrefixOf(path) {
+			return doc, fmt.Errorf("cannot move %s into itself", from)
+		}
+		val, err := from.Get(doc)
+		if err != nil {
+			return doc, err
+		}
+		if len(path) == 0 {
+			return val, nil
+		}
+		if err := from.Delete(doc); err != nil {
+			return doc, err
+		}
+		return doc, path.add(doc, val)
+	case PatchTest:
+		val, err := path.Get(doc)
+		if err != nil {
+			return doc, err
+		}
+		if !val.Equal(op.Value) {
+			return doc, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return doc, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// add implements the add operation: it inserts into arrays, but replaces dictionary entries.
+func (p Path) add(root Value, val Value) error {
+	parent, err := p.parent("add", root)
+	if err != nil {
+		return err
+	}
+	if parent.Kind() == DictionaryKind {
+		return p.Set(root, val)
+	}
+	return p.Insert(root, val)
+}
+
+func (p Path) isPrefixOf(o Path) bool {
+	if len(p) > len(o) {
+		return false
+	}
+	for i := range p {
+		if p[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PatchFromDiff returns a Patch that makes the changes reported by Diff:
+// applying PatchFromDiff(Diff(a, b)) to a produces a value equal to b.
+func PatchFromDiff(changes []Change) Patch {
+	patch := make(Patch, 0, len(changes))
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		switch c.Type {
+		case Added:
+			patch = append(patch, PatchOp{Op: PatchAdd, Path: c.Path.String(), Value: c.New})
+		case Modified, TypeChanged:
+			patch = append(patch, PatchOp{Op: PatchReplace, Path: c.Path.String(), Value: c.New})
+		case Removed:
+			// Diff reports the removals from an array first, in ascending order;
+			// remove them from the end so that earlier removals don't shift later ones.
+			j := i
+			for j+1 < len(changes) && changes[j+1].Type == Removed && sameParent(c.Path, changes[j+1].Path) {
+				j++
+			}
+			for k := j; k >= i; k-- {
+				patch = append(patch, PatchOp{Op: PatchRemove, Path: changes[k].Path.String()})
+			}
+			i = j
+		}
+	}
+	return patch
+}
+
+func sameParent(a, b Path) bool {
+	return len(a) > 0 && len(a) == len(b) && a[:len(a)-1].isPrefixOf(b)
+}
@@ -0,0 +1,200 @@
+package plist
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustValueOf(t *testing.T, x any) Value {
+	t.Helper()
+	v, err := ValueOf(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPatchApply(t *testing.T) {
+	doc := mustValueOf(t, map[string]any{
+		"CFBundleIdentifier": "com.example.app",
+		"CFBundleURLTypes":   []any{"one", "two"},
+		"Old":                map[string]any{"Key": "moved"},
+	})
+
+	patch := Patch{
+		{Op: PatchTest, Path: "/CFBundleIdentifier", Value: NewString("com.example.app")},
+		{Op: PatchReplace, Path: "/CFBundleIdentifier", Value: NewString("com.example.staging")},
+		{Op: PatchAdd, Path: "/CFBundleURLTypes/1", Value: NewString("inserted")},
+		{Op: PatchAdd, Path: "/CFBundleURLTypes/-", Value: NewString("appended")},
+		{Op: PatchRemove, Path: ":CFBundleURLTypes:0"},
+		{Op: PatchMove, From: "/Old/Key", Path: "/New"},
+		{Op: PatchAdd, Path: "Old.Flag", Value: NewBool(true)},
+	}
+
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"CFBundleIdentifier": "com.example.staging",
+		"CFBundleURLTypes":   []any{"inserted", "two", "appended"},
+		"Old":                map[string]any{"Flag": true},
+		"New":                "moved",
+	}
+	if val := patched.Interface(); !reflect.DeepEqual(val, expected) {
+		t.Errorf("expected %#v, got %#v", expected, val)
+	}
+	if id, _ := doc.Lookup("CFBundleIdentifier"); id.Interface() != "com.example.app" {
+		t.Error("expected the original document to be left unchanged")
+	}
+
+	failures := []struct {
+		Name string
+		Op   PatchOp
+		Err  error
+	}{
+		{"Failed Test", PatchOp{Op: PatchTest, Path: "/CFBundleIdentifier", Value: NewString("wrong")}, ErrTestFailed},
+		{"Replace Missing", PatchOp{Op: PatchReplace, Path: "/Missing", Value: NewBool(true)}, ErrKeyNotFound},
+		{"Remove Missing", PatchOp{Op: PatchRemove, Path: "/CFBundleURLTypes/5"}, ErrIndexOutOfRange},
+	}
+	for _, f := range failures {
+		subtest(t, f.Name, func(t *testing.T) {
+			_, err := Patch{{Op: PatchAdd, Path: "/Extra", Value: NewBool(true)}, f.Op}.Apply(doc)
+			var patchErr *PatchError
+			if !errors.As(err, &patchErr) || patchErr.Index != 1 || !errors.Is(err, f.Err) {
+				t.Errorf("expected %v from operation 1, got %v", f.Err, err)
+			}
+			if _, ok := doc.Lookup("Extra"); ok {
+				t.Error("expected a failed patch to leave the document unchanged")
+			}
+		})
+	}
+}
+
+func TestPatchSerialization(t *testing.T) {
+	patch := Patch{
+		{Op: PatchAdd, Path: "/a", Value: mustValueOf(t, map[string]any{"n": int64(-1), "f": 1.5})},
+		{Op: PatchMove, From: "/b", Path: "/c"},
+		{Op: PatchTest, Path: "/d", Value: NewUID(4)},
+	}
+
+	subtest(t, "JSON", func(t *testing.T) {
+		doc, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move := `{"op":"move","path":"/c","from":"/b"}`; !strings.Contains(string(doc), move) {
+			t.Errorf("expected %s in %s", move, doc)
+		}
+		var decoded Patch
+		if err := json.Unmarshal(doc, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assertPatchesEqual(t, patch, decoded)
+	})
+
+	for _, format := range []int{BinaryFormat, XMLFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(patch, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Patch
+			if _, err := Unmarshal(doc, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			assertPatchesEqual(t, patch, decoded)
+		})
+	}
+}
+
+func TestPatchWithNullFromJSON(t *testing.T) {
+	var patch Patch
+	if err := json.Unmarshal([]byte(`[{"op":"add","path":"/list/1","value":null},{"op":"add","path":"/none","value":null}]`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := patch.Apply(mustValueOf(t, map[string]any{"list": []any{"a", "b"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewDictionary()
+	expected.Set("list", NewArray(NewString("a"), NewNull(), NewString("b")))
+	expected.Set("none", NewNull())
+	if !doc.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected.Interface(), doc.Interface())
+	}
+}
+
+func assertPatchesEqual(t *testing.T, expected, got Patch) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d operations, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		e, g := expected[i], got[i]
+		if e.Op != g.Op || e.Path != g.Path || e.From != g.From || !e.Value.Equal(g.Value) {
+			t.Errorf("operation %d: expected %+v, got %+v", i, e, g)
+		}
+	}
+}
+
+func TestPatchFromDiff(t *testing.T) {
+	a := mustValueOf(t, map[string]any{"list": []any{1, 2, 3, 4}, "gone": true, "same": "x"})
+	b := mustValueOf(t, map[string]any{"list": []any{1, 5}, "new": "y", "same": "x"})
+
+	patched, err := PatchFromDiff(Diff(a, b)).Apply(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !patched.Equal(b) {
+		t.Errorf("expected %#v, got %#v", b.Interface(), patched.Interface())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := mustValueOf(t, map[string]any{
+		"name":    "base",
+		"servers": []any{"a", "b"},
+		"nested":  map[string]any{"keep": 1, "override": 2},
+		"kind":    []any{"array"},
+	})
+	overlay := mustValueOf(t, map[string]any{
+		"name":    "production",
+		"servers": []any{"b", "c"},
+		"nested":  map[string]any{"override": 3, "extra": 4},
+		"kind":    "scalar",
+	})
+
+	strategies := []struct {
+		Name     string
+		Arrays   ArrayMergeStrategy
+		Expected []any
+	}{
+		{"Replace", ArrayReplace, []any{"b", "c"}},
+		{"Append", ArrayAppend, []any{"a", "b", "b", "c"}},
+		{"Union", ArrayUnion, []any{"a", "b", "c"}},
+	}
+
+	for _, s := range strategies {
+		subtest(t, s.Name, func(t *testing.T) {
+			merged := Merge(base, overlay, MergeOptions{Arrays: s.Arrays})
+			expected := map[string]any{
+				"name":    "production",
+				"servers": s.Expected,
+				"nested":  map[string]any{"keep": int64(1), "override": int64(3), "extra": int64(4)},
+				"kind":    "scalar",
+			}
+			if val := merged.Interface(); !reflect.DeepEqual(val, expected) {
+				t.Errorf("expected %#v, got %#v", expected, val)
+			}
+		})
+	}
+
+	if servers, _ := base.Lookup("servers"); servers.Len() != 2 {
+		t.Error("expected Merge to leave base unchanged")
+	}
+}
@@ -0,0 +1,350 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Path addresses a value within a property list tree, as a list of segments
+// leading down from the root. Each segment is a dictionary key, or the decimal
+// index of an array element. The empty Path refers to the root itself.
+//
+// When inserting into an array, the final segment may also be "-", which refers
+// to the position after the last element.
+type Path []string
+
+// Errors reported (wrapped in a *PathError) by operations on a Path.
+var (
+	ErrKeyNotFound     = errors.New("no such key")
+	ErrKeyExists       = errors.New("key already exists")
+	ErrIndexOutOfRange = errors.New("index out of range")
+	ErrInvalidIndex    = errors.New("invalid array index")
+	ErrNotContainer    = errors.New("not an array or dictionary")
+	ErrRootNotWritable = errors.New("the root cannot be replaced or removed")
+
+	errPathSyntax = errors.New("invalid path syntax")
+)
+
+var (
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// A PathError records the segment of a Path at which an operation failed.
+type PathError struct {
+	Op      string // "parse", "get", "set", "insert" or "delete"
+	Path    string
+	Segment string // the segment that failed
+	Index   int    // position of Segment within the path
+	Err     error
+}
+
+func (e *PathError) Error() string {
+	if e.Op == "parse" {
+		return fmt.Sprintf("plist: %s path %q: %v", e.Op, e.Path, e.Err)
+	}
+	return fmt.Sprintf("plist: %s %s: at segment %d (%q): %v", e.Op, e.Path, e.Index, e.Segment, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ParsePath parses a path in any of three notations:
+//
+//	:CFBundleURLTypes:0:CFBundleURLSchemes:0    PlistBuddy
+//	/CFBundleURLTypes/0/CFBundleURLSchemes/0    JSON Pointer (RFC 6901)
+//	CFBundleURLTypes[0].CFBundleURLSchemes[0]   dotted keys and bracketed indexes
+//
+// PlistBuddy paths have no escape for a colon within a key, and dotted paths none
+// for a dot or bracket; JSON Pointer can express every key, using ~1 for "/" and ~0 for "~".
+func ParsePath(s string) (Path, error) {
+	var (
+		path Path
+		err  error
+	)
+	switch {
+	case s == "":
+		return Path{}, nil
+	case s[0] == ':':
+		path = parsePlistBuddyPath(s)
+	case s[0] == '/':
+		path, err = parseJSONPointer(s)
+	default:
+		path, err = parseDottedPath(s)
+	}
+	if err != nil {
+		return nil, &PathError{Op: "parse", Path: s, Err: err}
+	}
+	return path, nil
+}
+
+// MustParsePath is like ParsePath, but panics if s cannot be parsed.
+func MustParsePath(s string) Path {
+	path, err := ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+func parsePlistBuddyPath(s string) Path {
+	s = strings.TrimPrefix(s, ":")
+	if s == "" {
+		return Path{}
+	}
+	return Path(strings.Split(s, ":"))
+}
+
+func parseJSONPointer(s string) (Path, error) {
+	segments := strings.Split(s[1:], "/")
+	for i, seg := range segments {
+		for j := 0; j < len(seg); j++ {
+			if seg[j] == '~' && (j+1 == len(seg) || (seg[j+1] != '0' && seg[j+1] != '1')) {
+				return nil, fmt.Errorf("%w: bad escape in %q", errPathSyntax, seg)
+			}
+		}
+		segments[i] = jsonPointerUnescaper.Replace(seg)
+	}
+	return Path(segments), nil
+}
+
+func parseDottedPath(s string) (Path, error) {
+	var path Path
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated [ at offset %d", errPathSyntax, i)
+			}
+			index := s[i+1 : i+end]
+			if index != "-" {
+				if _, err := parseIndex(index); err != nil {
+					return nil, fmt.Errorf("%w: %q is not an array index", errPathSyntax, index)
+				}
+			}
+			path = append(path, index)
+			i += end + 1
+		case '.':
+			if i == 0 || i == len(s)-1 || s[i+1] == '.' || s[i+1] == '[' {
+				return nil, fmt.Errorf("%w: empty key at offset %d", errPathSyntax, i)
+			}
+			i++
+		default:
+			if i > 0 && s[i-1] == ']' {
+				return nil, fmt.Errorf("%w: expected . or [ at offset %d", errPathSyntax, i)
+			}
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			path = append(path, s[i:i+end])
+			i += end
+		}
+	}
+	return path, nil
+}
+
+// parseIndex parses an array index, which must be a non-negative decimal integer.
+func parseIndex(seg string) (int, error) {
+	if seg == "" || seg[0] == '+' || seg[0] == '-' {
+		return 0, ErrInvalidIndex
+	}
+	i, err := strconv.Atoi(seg)
+	if err != nil {
+		return 0, ErrInvalidIndex
+	}
+	return i, nil
+}
+
+// String returns p as a JSON Pointer.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, seg := range p {
+		b.WriteByte('/')
+		b.WriteString(jsonPointerEscaper.Replace(seg))
+	}
+	return b.String()
+}
+
+func (p Path) error(op string, i int, err error) error {
+	seg := ""
+	if i < len(p) {
+		seg = p[i]
+	}
+	return &PathError{Op: op, Path: p.String(), Segment: seg, Index: i, Err: err}
+}
+
+// walk returns the value addressed by the first n segments of p.
+func (p Path) walk(op string, root Value, n int) (Value, error) {
+	v := root
+	for i, seg := range p[:n] {
+		switch v.Kind() {
+		case DictionaryKind:
+			next, ok := v.Lookup(seg)
+			if !ok {
+				return Value{}, p.error(op, i, ErrKeyNotFound)
+			}
+			v = next
+		case ArrayKind, SetKind, OrderedSetKind:
+			index, err := parseIndex(seg)
+			if err != nil {
+				return Value{}, p.error(op, i, err)
+			}
+			if index >= v.Len() {
+				return Value{}, p.error(op, i, ErrIndexOutOfRange)
+			}
+			v = v.Index(index)
+		default:
+			return Value{}, p.error(op, i, fmt.Errorf("%w (found %v)", ErrNotContainer, v.Kind()))
+		}
+	}
+	return v, nil
+}
+
+// parent returns the container holding the value addressed by p, which must not be the root.
+func (p Path) parent(op string, root Value) (Value, error) {
+	if len(p) == 0 {
+		return Value{}, p.error(op, 0, ErrRootNotWritable)
+	}
+	parent, err := p.walk(op, root, len(p)-1)
+	if err != nil {
+		return Value{}, err
+	}
+	if k := parent.Kind(); k != DictionaryKind && !k.isArray() {
+		return Value{}, p.error(op, len(p)-1, fmt.Errorf("%w (found %v)", ErrNotContainer, k))
+	}
+	return parent, nil
+}
+
+// arrayIndex parses the final segment of p as an index into arr; "-" refers to the end.
+// Indexes up to and including the length of arr are accepted if end is set.
+func (p Path) arrayIndex(op string, arr Value, end bool) (int, error) {
+	last := len(p) - 1
+	if p[last] == "-" {
+		if end {
+			return arr.Len(), nil
+		}
+		return 0, p.error(op, last, ErrIndexOutOfRange)
+	}
+	index, err := parseIndex(p[last])
+	if err != nil {
+		return 0, p.error(op, last, err)
+	}
+	if index > arr.Len() || (index == arr.Len() && !end) {
+		return 0, p.error(op, last, ErrIndexOutOfRange)
+	}
+	return index, nil
+}
+
+// Get returns the value addressed by p within root.
+func (p Path) Get(root Value) (Value, error) {
+	return p.walk("get", root, len(p))
+}
+
+// Set stores val at p within root. A dictionary key is added if it does not exist;
+// an array element must already exist, unless p ends in "-" or the array's length,
+// in which case val is appended.
+func (p Path) Set(root Value, val Value) error {
+	parent, err := p.parent("set", root)
+	if err != nil {
+		return err
+	}
+	if parent.Kind() == DictionaryKind {
+		parent.Set(p[len(p)-1], val)
+		return nil
+	}
+	index, err := p.arrayIndex("set", parent, true)
+	if err != nil {
+		return err
+	}
+	if index == parent.Len() {
+		parent.Append(val)
+	} else {
+		parent.SetIndex(index, val)
+	}
+	return nil
+}
+
+// Insert adds val at p within root. A dictionary key must not already exist;
+// in an array, val is inserted before the element at p, or appended if p ends
+// in "-" or the array's length.
+func (p Path) Insert(root Value, val Value) error {
+	parent, err := p.parent("insert", root)
+	if err != nil {
+		return err
+	}
+	if parent.Kind() == DictionaryKind {
+		key := p[len(p)-1]
+		if _, ok := parent.Lookup(key); ok {
+			return p.error("insert", len(p)-1, ErrKeyExists)
+		}
+		parent.Set(key, val)
+		return nil
+	}
+	index, err := p.arrayIndex("insert", parent, true)
+	if err != nil {
+		return err
+	}
+	parent.Insert(index, val)
+	return nil
+}
+
+// Delete removes the value addressed by p from root.
+func (p Path) Delete(root Value) error {
+	parent, err := p.parent("delete", root)
+	if err != nil {
+		return err
+	}
+	if parent.Kind() == DictionaryKind {
+		if !parent.Delete(p[len(p)-1]) {
+			return p.error("delete", len(p)-1, ErrKeyNotFound)
+		}
+		return nil
+	}
+	index, err := p.arrayIndex("delete", parent, false)
+	if err != nil {
+		return err
+	}
+	parent.DeleteIndex(index)
+	return nil
+}
+
+// GetPath returns the value addressed by path, in any notation accepted by ParsePath.
+func (v Value) GetPath(path string) (Value, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return Value{}, err
+	}
+	return p.Get(v)
+}
+
+// SetPath stores val at path; see Path.Set.
+func (v Value) SetPath(path string, val Value) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return p.Set(v, val)
+}
+
+// InsertPath adds val at path; see Path.Insert.
+func (v Value) InsertPath(path string, val Value) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return p.Insert(v, val)
+}
+
+// DeletePath removes the value at path; see Path.Delete.
+func (v Value) DeletePath(path string) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return p.Delete(v)
+}
@@ -0,0 +1,122 @@
+package plist
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	pathTests := []struct {
+		In       string
+		Expected Path
+	}{
+		{"", Path{}},
+		{":", Path{}},
+		{"/", Path{""}},
+		{":CFBundleURLTypes:0:CFBundleURLSchemes:0", Path{"CFBundleURLTypes", "0", "CFBundleURLSchemes", "0"}},
+		{"/CFBundleURLTypes/0/CFBundleURLSchemes/0", Path{"CFBundleURLTypes", "0", "CFBundleURLSchemes", "0"}},
+		{"CFBundleURLTypes[0].CFBundleURLSchemes[0]", Path{"CFBundleURLTypes", "0", "CFBundleURLSchemes", "0"}},
+		{"/a~1b/c~0d", Path{"a/b", "c~d"}},
+		{"[1][2].x", Path{"1", "2", "x"}},
+		{"list[-]", Path{"list", "-"}},
+		{"com.apple.key", Path{"com", "apple", "key"}},
+	}
+
+	for _, pt := range pathTests {
+		subtest(t, pt.In, func(t *testing.T) {
+			path, err := ParsePath(pt.In)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path, pt.Expected) {
+				t.Errorf("expected %#v, got %#v", pt.Expected, path)
+			}
+		})
+	}
+
+	for _, bad := range []string{"a..b", ".a", "a.", "a[0", "a[x]", "a[0]b", "/a~2"} {
+		subtest(t, bad, func(t *testing.T) {
+			var pathErr *PathError
+			if _, err := ParsePath(bad); !errors.As(err, &pathErr) || pathErr.Op != "parse" {
+				t.Errorf("expected a parse error, got %v", err)
+			}
+		})
+	}
+
+	if s := (Path{"a/b", "c~d", "0"}).String(); s != "/a~1b/c~0d/0" {
+		t.Errorf("expected JSON Pointer /a~1b/c~0d/0, got %s", s)
+	}
+}
+
+func TestPathOperations(t *testing.T) {
+	root, err := ValueOf(map[string]any{
+		"CFBundleURLTypes": []any{
+			map[string]any{"CFBundleURLSchemes": []any{"example"}},
+		},
+		"CFBundleName": "Example",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, err := root.GetPath("CFBundleURLTypes[0].CFBundleURLSchemes[0]")
+	if s, _ := scheme.AsString(); err != nil || s != "example" {
+		t.Fatalf("expected example, got %q (%v)", s, err)
+	}
+
+	if err := root.InsertPath(":CFBundleURLTypes:0:CFBundleURLSchemes:0", NewString("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPath("/CFBundleURLTypes/0/CFBundleURLSchemes/-", NewString("last")); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPath("CFBundleURLTypes[0].CFBundleURLName", NewString("com.example")); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.DeletePath(":CFBundleName"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"CFBundleURLTypes": []any{
+			map[string]any{
+				"CFBundleURLSchemes": []any{"first", "example", "last"},
+				"CFBundleURLName":    "com.example",
+			},
+		},
+	}
+	if val := root.Interface(); !reflect.DeepEqual(val, expected) {
+		t.Errorf("expected %#v, got %#v", expected, val)
+	}
+
+	errorTests := []struct {
+		Name    string
+		Op      func() error
+		Segment string
+		Index   int
+		Err     error
+	}{
+		{"Missing Key", func() error { _, err := root.GetPath(":CFBundleURLTypes:0:Missing:0"); return err }, "Missing", 2, ErrKeyNotFound},
+		{"Index Out of Range", func() error { _, err := root.GetPath("CFBundleURLTypes[3]"); return err }, "3", 1, ErrIndexOutOfRange},
+		{"Key Into Array", func() error { _, err := root.GetPath("CFBundleURLTypes.first"); return err }, "first", 1, ErrInvalidIndex},
+		{"Descend Into String", func() error { _, err := root.GetPath("CFBundleURLTypes[0].CFBundleURLName.x"); return err }, "x", 3, ErrNotContainer},
+		{"Insert Existing Key", func() error { return root.InsertPath("/CFBundleURLTypes/0/CFBundleURLName", NewBool(true)) }, "CFBundleURLName", 2, ErrKeyExists},
+		{"Delete Missing Key", func() error { return root.DeletePath("/CFBundleName") }, "CFBundleName", 0, ErrKeyNotFound},
+		{"Delete Past End", func() error { return root.DeletePath("/CFBundleURLTypes/-") }, "-", 1, ErrIndexOutOfRange},
+		{"Set Root", func() error { return root.SetPath("", NewBool(true)) }, "", 0, ErrRootNotWritable},
+	}
+
+	for _, et := range errorTests {
+		subtest(t, et.Name, func(t *testing.T) {
+			err := et.Op()
+			var pathErr *PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("expected a PathError, got %v", err)
+			}
+			if pathErr.Segment != et.Segment || pathErr.Index != et.Index || !errors.Is(err, et.Err) {
+				t.Errorf("expected %v at segment %d (%q), got %v", et.Err, et.Index, et.Segment, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package plist
 
 import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
 	"reflect"
 )
 
@@ -47,6 +50,10 @@ func (e invalidPlistError) Error() string {
 	return s
 }
 
+func (e invalidPlistError) Unwrap() error {
+	return e.err
+}
+
 type plistParseError struct {
 	format string
 	err    error
@@ -60,10 +67,65 @@ func (e plistParseError) Error() string {
 	return s
 }
 
+func (e plistParseError) Unwrap() error {
+	return e.err
+}
+
 // A UID represents a unique object identifier. UIDs are serialized in a manner distinct from
 // that of integers.
 type UID uint64
 
+// A UUID is a universally unique identifier. Binary property lists store UUIDs natively;
+// in XML and text property lists they are written as strings in the canonical form
+// (see UUID.String), from which they can also be decoded.
+type UUID [16]byte
+
+// String returns u in its canonical form, as five groups of upper-case hexadecimal digits:
+// XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX.
+func (u UUID) String() string {
+	b, _ := u.MarshalText()
+	return string(b)
+}
+
+// MarshalText implements encoding.TextMarshaler, using the canonical form.
+func (u UUID) MarshalText() ([]byte, error) {
+	const digits = "0123456789ABCDEF"
+	b := make([]byte, 0, 36)
+	for i, c := range u {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			b = append(b, '-')
+		}
+		b = append(b, digits[c>>4], digits[c&0xF])
+	}
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the canonical form in either case.
+func (u *UUID) UnmarshalText(text []byte) error {
+	if len(text) != 36 || text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+		return fmt.Errorf("plist: invalid UUID %q", text)
+	}
+	digits := make([]byte, 0, 32)
+	for _, group := range bytes.Split(text, []byte("-")) {
+		digits = append(digits, group...)
+	}
+	var parsed UUID
+	if _, err := hex.Decode(parsed[:], digits); err != nil || len(digits) != 32 {
+		return fmt.Errorf("plist: invalid UUID %q", text)
+	}
+	*u = parsed
+	return nil
+}
+
+// A Set is an unordered collection of values. Binary property lists store sets natively;
+// in XML and text property lists they are written as arrays. Marshal does not check
+// that the values of a Set are distinct.
+type Set []any
+
+// An OrderedSet is a collection of distinct values that keeps its order. Binary property
+// lists store ordered sets natively; in XML and text property lists they are written as arrays.
+type OrderedSet []any
+
 // Marshaler is the interface implemented by types that can marshal themselves into valid
 // property list objects. The returned value is marshaled in place of the original value
 // implementing Marshaler
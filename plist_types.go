@@ -1,7 +1,7 @@
 package plist
 
 import (
-	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 	"time"
@@ -46,6 +46,21 @@ func (p *cfDictionary) sort() {
 	sort.Sort(p)
 }
 
+// sorted returns the dictionary with its keys in sorted order. The dictionary
+// may belong to the caller (as a Value or RawValue), so it is copied rather than
+// sorted in place.
+func (p *cfDictionary) sorted() *cfDictionary {
+	if sort.IsSorted(p) {
+		return p
+	}
+	c := &cfDictionary{
+		keys:   append(sort.StringSlice(nil), p.keys...),
+		values: append([]cfValue(nil), p.values...),
+	}
+	c.sort()
+	return c
+}
+
 func (p *cfDictionary) maybeUID(lax bool) cfValue {
 	if len(p.keys) == 1 && p.keys[0] == "CF$UID" && len(p.values) == 1 {
 		pval := p.values[0]
@@ -66,9 +81,25 @@ func (p *cfDictionary) maybeUID(lax bool) cfValue {
 
 type cfArray struct {
 	values []cfValue
+	set    cfSetKind
 }
 
-func (*cfArray) typeName() string {
+// cfSetKind distinguishes the sets of a binary property list, which are otherwise treated as arrays.
+type cfSetKind uint8
+
+const (
+	cfNotSet cfSetKind = iota
+	cfUnorderedSet
+	cfOrderedSet
+)
+
+func (p *cfArray) typeName() string {
+	switch p.set {
+	case cfUnorderedSet:
+		return "set"
+	case cfOrderedSet:
+		return "ordered set"
+	}
 	return "array"
 }
 
@@ -89,13 +120,49 @@ func (p cfString) hash() any {
 type cfNumber struct {
 	signed bool
 	value  uint64
+	hi     uint64 // the high 64 bits of an integer that fits in neither int64 nor uint64; zero otherwise
+}
+
+// newInt128Number returns i as a cfNumber, using only 64 bits if it can.
+func newInt128Number(i Int128) *cfNumber {
+	switch {
+	case i.IsUint64():
+		return &cfNumber{signed: false, value: i.Lo}
+	case i.IsInt64():
+		return &cfNumber{signed: true, value: i.Lo}
+	}
+	return &cfNumber{signed: true, value: i.Lo, hi: uint64(i.Hi)}
 }
 
 func (*cfNumber) typeName() string {
 	return "integer"
 }
 
+func (p *cfNumber) int128() Int128 {
+	switch {
+	case p.hi != 0:
+		return Int128{Hi: int64(p.hi), Lo: p.value}
+	case p.signed:
+		return Int128FromInt64(int64(p.value))
+	}
+	return Int128FromUint64(p.value)
+}
+
+// decimal returns the integer in decimal, as it is written in XML and text property lists.
+func (p *cfNumber) decimal() string {
+	switch {
+	case p.hi != 0:
+		return p.int128().String()
+	case p.signed:
+		return strconv.FormatInt(int64(p.value), 10)
+	}
+	return strconv.FormatUint(p.value, 10)
+}
+
 func (p *cfNumber) hash() any {
+	if p.hi != 0 {
+		return p.int128()
+	}
 	if p.signed {
 		return int64(p.value)
 	}
@@ -111,11 +178,18 @@ func (cfReal) typeName() string {
 	return "real"
 }
 
+// realKey identifies a real by its width and bits, so that a NaN is equal to itself
+// and 0 and -0 are different reals.
+type realKey struct {
+	wide bool
+	bits uint64
+}
+
 func (p *cfReal) hash() any {
 	if p.wide {
-		return p.value
+		return realKey{true, math.Float64bits(p.value)}
 	}
-	return float32(p.value)
+	return realKey{false, uint64(math.Float32bits(float32(p.value)))}
 }
 
 type cfBoolean bool
@@ -127,6 +201,38 @@ func (cfBoolean) typeName() string {
 func (p cfBoolean) hash() any {
 	return bool(p)
 }
+// cfNull is the null object of binary property lists.
+// cfNull is the null object of newer binary property lists.
+type cfNull struct{}
+
+func (cfNull) typeName() string {
+	return "null"
+}
+
+func (cfNull) hash() any {
+	return cfNull{}
+}
+
+// cfURL is a URL from a binary property list, resolved against its base URL if it had one.
+type cfURL string
+
+func (cfURL) typeName() string {
+	return "URL"
+}
+
+func (p cfURL) hash() any {
+	return p
+}
+
+type cfUUID UUID
+
+func (cfUUID) typeName() string {
+	return "UUID"
+}
+
+func (p cfUUID) hash() any {
+	return p
+}
 
 type cfUID UID
 
@@ -154,11 +260,12 @@ func (cfData) typeName() string {
 	return "data"
 }
 
+// dataKey identifies data by their full contents; it is a type of its own so that
+// data are never mistaken for a string with the same bytes.
+type dataKey string
+
 func (p cfData) hash() any {
-	// Data are uniqued by their checksums.
-	// Todo: Look at calculating this only once and storing it somewhere;
-	// crc32 is fairly quick, however.
-	return crc32.ChecksumIEEE([]byte(p))
+	return dataKey(p)
 }
 
 type cfDate time.Time
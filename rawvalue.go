@@ -0,0 +1,30 @@
+package plist
+
+import (
+	"reflect"
+)
+
+// RawValue holds a property list value that has been parsed, but not yet decoded.
+//
+// A RawValue field captures its part of the document unchanged, so that it can
+// later be decoded with Unmarshal once its type is known (for example, from a sibling key).
+// An Encoder writes a RawValue out exactly as it was parsed, in any format.
+//
+// The zero RawValue holds nothing, and is omitted when encoding a struct or map.
+type RawValue struct {
+	pval cfValue
+	lax  bool // the value came from an OpenStep document, where every scalar is a string
+}
+
+var rawValueType = reflect.TypeOf(RawValue{})
+
+// IsZero reports whether r holds no value.
+func (r RawValue) IsZero() bool {
+	return r.pval == nil
+}
+
+// Unmarshal decodes the captured value into v, following the same rules as a Decoder.
+func (r RawValue) Unmarshal(v any) error {
+	p := &Decoder{Format: InvalidFormat, lax: r.lax}
+	return p.unmarshalDocument(r.pval, reflect.ValueOf(v))
+}
@@ -0,0 +1,154 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRawValueDeferredDecode(t *testing.T) {
+	type wifiPayload struct {
+		Type string `plist:"PayloadType"`
+		SSID string `plist:"SSID_STR"`
+	}
+	type vpnPayload struct {
+		Type   string `plist:"PayloadType"`
+		Server string `plist:"RemoteAddress"`
+	}
+	type profile struct {
+		Identifier string     `plist:"PayloadIdentifier"`
+		Content    []RawValue `plist:"PayloadContent"`
+	}
+
+	doc, err := Marshal(map[string]any{
+		"PayloadIdentifier": "com.example.profile",
+		"PayloadContent": []any{
+			map[string]any{"PayloadType": "com.apple.wifi.managed", "SSID_STR": "office"},
+			map[string]any{"PayloadType": "com.apple.vpn.managed", "RemoteAddress": "vpn.example.com"},
+		},
+	}, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p profile
+	if _, err := Unmarshal(doc, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []any
+	for _, raw := range p.Content {
+		var header struct {
+			Type string `plist:"PayloadType"`
+		}
+		if err := raw.Unmarshal(&header); err != nil {
+			t.Fatal(err)
+		}
+
+		switch header.Type {
+		case "com.apple.wifi.managed":
+			var wifi wifiPayload
+			if err := raw.Unmarshal(&wifi); err != nil {
+				t.Fatal(err)
+			}
+			decoded = append(decoded, wifi)
+		case "com.apple.vpn.managed":
+			var vpn vpnPayload
+			if err := raw.Unmarshal(&vpn); err != nil {
+				t.Fatal(err)
+			}
+			decoded = append(decoded, vpn)
+		}
+	}
+
+	expected := []any{
+		wifiPayload{"com.apple.wifi.managed", "office"},
+		vpnPayload{"com.apple.vpn.managed", "vpn.example.com"},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %#v, got %#v", expected, decoded)
+	}
+}
+
+func TestRawValueRoundTrip(t *testing.T) {
+	value := map[string]any{
+		"Payload": map[string]any{
+			"signed":   int64(-5),
+			"unsigned": uint64(1 << 63),
+			"float32":  float32(1.5),
+			"float64":  2.25,
+			"data":     []byte{1, 2, 3},
+			"date":     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			"uid":      UID(7),
+			"list":     []any{true, "text"},
+		},
+		"Other": "kept",
+	}
+
+	// OpenStep has no representation for most of the types above.
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(value, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wrapper struct {
+				Payload RawValue
+				Other   string
+			}
+			if _, err := Unmarshal(doc, &wrapper); err != nil {
+				t.Fatal(err)
+			}
+			if wrapper.Payload.IsZero() {
+				t.Fatal("expected the payload to be captured")
+			}
+
+			out, err := Marshal(wrapper, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(doc, out) {
+				t.Errorf("expected re-encoding to reproduce the document\nexpected: %q\ngot:      %q", doc, out)
+			}
+		})
+	}
+
+	var empty struct {
+		Payload RawValue
+		Other   string
+	}
+	empty.Other = "kept"
+	doc, err := Marshal(empty, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip map[string]any
+	if _, err := Unmarshal(doc, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := roundTrip["Payload"]; ok {
+		t.Error("expected a zero RawValue to be omitted")
+	}
+}
+
+func TestRawValueFromOpenStep(t *testing.T) {
+	var wrapper struct {
+		Payload RawValue
+	}
+	if _, err := Unmarshal([]byte(`{Payload = {Count = 3; Enabled = 1;};}`), &wrapper); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Count   int
+		Enabled bool
+	}
+	if err := wrapper.Payload.Unmarshal(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Count != 3 || !payload.Enabled {
+		t.Errorf("expected OpenStep strings to be decoded laxly, got %+v", payload)
+	}
+}
@@ -0,0 +1,60 @@
+package plist
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseReal parses a real from an XML or text property list. With preserveFloat32,
+// a finite real written as the shortest decimal of a 32-bit real is a 32-bit real;
+// otherwise, every real is 64-bit, as XML and text property lists do not say.
+func parseReal(s string, preserveFloat32 bool) (*cfReal, error) {
+	// strconv.ParseFloat accepts every spelling of a non-finite real that CoreFoundation
+	// reads (nan, inf and infinity, in any case and with an optional sign), and CoreFoundation
+	// writes nan, +infinity and -infinity, so no spelling needs special treatment.
+	s = strings.TrimSpace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	if preserveFloat32 && isShortestFloat32(s) {
+		f32, _ := strconv.ParseFloat(s, 32)
+		return &cfReal{wide: false, value: f32}, nil
+	}
+	return &cfReal{wide: true, value: f}, nil
+}
+
+// isShortestFloat32 reports whether s is exactly how a finite 32-bit real is written in the fewest digits.
+func isShortestFloat32(s string) bool {
+	f32, err := strconv.ParseFloat(s, 32)
+	if err != nil || math.IsInf(f32, 0) || math.IsNaN(f32) {
+		return false
+	}
+	return strconv.FormatFloat(f32, 'g', -1, 32) == s
+}
+
+// formatReal formats a finite real for an XML or text property list, in the fewest digits that
+// identify it as a 64-bit real. With preserveFloat32, a 32-bit real is instead written in the fewest
+// digits that identify it as a 32-bit real, and a 64-bit real that would then be read back as a
+// 32-bit one is written in exponent form with a trailing zero, which a 32-bit real never has.
+func formatReal(r *cfReal, preserveFloat32 bool) string {
+	if !preserveFloat32 {
+		return strconv.FormatFloat(r.value, 'g', -1, 64)
+	}
+	if !r.wide {
+		return strconv.FormatFloat(r.value, 'g', -1, 32)
+	}
+
+	s := strconv.FormatFloat(r.value, 'g', -1, 64)
+	if !isShortestFloat32(s) {
+		return s
+	}
+	e := strconv.FormatFloat(r.value, 'e', -1, 64)
+	mantissa := e[:strings.IndexByte(e, 'e')]
+	decimals := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		decimals = len(mantissa) - i - 1
+	}
+	return strconv.FormatFloat(r.value, 'e', decimals+1, 64)
+}
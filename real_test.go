@@ -0,0 +1,111 @@
+package plist
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRealSpellings(t *testing.T) {
+	for _, test := range []struct {
+		Spelling string
+		Expected float64
+	}{
+		{"nan", math.NaN()},
+		{"NaN", math.NaN()},
+		{"+infinity", math.Inf(1)},
+		{"-infinity", math.Inf(-1)},
+		{"infinity", math.Inf(1)},
+		{"Infinity", math.Inf(1)},
+		{"inf", math.Inf(1)},
+		{"+inf", math.Inf(1)},
+		{"-inf", math.Inf(-1)},
+		{"-Inf", math.Inf(-1)},
+		{" 1.5\n", 1.5},
+	} {
+		subtest(t, test.Spelling, func(t *testing.T) {
+			for _, doc := range []string{
+				"<plist><real>" + test.Spelling + "</real></plist>",
+				"<*R" + test.Spelling + ">",
+			} {
+				var got float64
+				if _, err := Unmarshal([]byte(doc), &got); err != nil {
+					t.Fatalf("%s: %v", doc, err)
+				}
+				if got != test.Expected && !(math.IsNaN(got) && math.IsNaN(test.Expected)) {
+					t.Errorf("%s: expected %v, got %v", doc, test.Expected, got)
+				}
+			}
+		})
+	}
+
+	if _, err := Unmarshal([]byte("<plist><real>infinite</real></plist>"), new(float64)); err == nil {
+		t.Error("expected an error for an invalid real")
+	}
+}
+
+func TestXMLNonFiniteReals(t *testing.T) {
+	for _, test := range []struct {
+		Value    float64
+		Expected string
+	}{
+		{math.Inf(1), "<real>+infinity</real>"},
+		{math.Inf(-1), "<real>-infinity</real>"},
+		{math.NaN(), "<real>nan</real>"},
+	} {
+		doc, err := Marshal(test.Value, XMLFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(doc), test.Expected) {
+			t.Errorf("expected %s in %s", test.Expected, doc)
+		}
+	}
+}
+
+func TestPreserveFloat32(t *testing.T) {
+	value := map[string]any{
+		"Tenth32":  float32(0.1),
+		"Tenth64":  0.1,
+		"One32":    float32(1),
+		"One64":    1.0,
+		"Large32":  float32(3.4e38),
+		"Precise":  math.Pi,
+		"Infinity": math.Inf(1),
+	}
+
+	for _, format := range []int{XMLFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, format)
+			enc.PreserveFloat32()
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), "1.0e-01") || strings.Contains(buf.String(), "0.10000000149011612") {
+				t.Errorf("expected shortest 32-bit reals and marked 64-bit ones, got %s", buf.String())
+			}
+
+			var got map[string]any
+			dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+			dec.PreserveFloat32()
+			if err := dec.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Errorf("expected %#v, got %#v", value, got)
+			}
+
+			// Without the option, every real is 64-bit, but 32-bit values are unchanged.
+			var wide map[string]any
+			if _, err := Unmarshal(buf.Bytes(), &wide); err != nil {
+				t.Fatal(err)
+			}
+			if wide["Tenth32"] != 0.1 || wide["Tenth64"] != 0.1 || wide["One32"] != 1.0 {
+				t.Errorf("unexpected reals %#v", wide)
+			}
+		})
+	}
+}
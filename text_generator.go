@@ -3,6 +3,7 @@ package plist
 import (
 	"encoding/hex"
 	"io"
+	"math"
 	"strconv"
 	"time"
 )
@@ -13,8 +14,11 @@ type textPlistGenerator struct {
 
 	quotableTable *characterSet
 
-	indent string
-	depth  int
+	indent    string
+	depth     int
+	keepOrder bool
+	precision time.Duration
+	float32   bool
 
 	dictKvDelimiter, dictEntryDelimiter, arrayDelimiter []byte
 }
@@ -106,7 +110,9 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 
 	switch pval := pval.(type) {
 	case *cfDictionary:
-		pval.sort()
+		if !p.keepOrder {
+			pval = pval.sorted()
+		}
 		p.writer.Write([]byte(`{`))
 		p.deltaIndent(1)
 		for i, k := range pval.keys {
@@ -136,11 +142,7 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`<*I`))
 		}
-		if pval.signed {
-			io.WriteString(p.writer, strconv.FormatInt(int64(pval.value), 10))
-		} else {
-			io.WriteString(p.writer, strconv.FormatUint(pval.value, 10))
-		}
+		io.WriteString(p.writer, pval.decimal())
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`>`))
 		}
@@ -148,8 +150,12 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`<*R`))
 		}
-		// GNUstep does not differentiate between 32/64-bit floats.
-		io.WriteString(p.writer, strconv.FormatFloat(pval.value, 'g', -1, 64))
+		// GNUstep does not differentiate between 32/64-bit floats; see Encoder.PreserveFloat32.
+		if math.IsInf(pval.value, 0) || math.IsNaN(pval.value) {
+			io.WriteString(p.writer, strconv.FormatFloat(pval.value, 'g', -1, 64))
+		} else {
+			io.WriteString(p.writer, formatReal(pval, p.float32))
+		}
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`>`))
 		}
@@ -190,18 +196,42 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 		}
 		p.writer.Write([]byte(`>`))
 	case cfDate:
+		date := time.Time(pval).Truncate(p.precision).In(time.UTC).Format(textPlistTimeLayoutNano)
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`<*D`))
-			io.WriteString(p.writer, time.Time(pval).In(time.UTC).Format(textPlistTimeLayout))
+			io.WriteString(p.writer, date)
 			p.writer.Write([]byte(`>`))
 		} else {
-			io.WriteString(p.writer, p.plistQuotedString(time.Time(pval).In(time.UTC).Format(textPlistTimeLayout)))
+			io.WriteString(p.writer, p.plistQuotedString(date))
 		}
 	case cfUID:
 		p.writePlistValue(pval.toDict())
+	case cfNull:
+		// A text property list has no null; an empty string stands in for it.
+		p.writePlistValue(cfString(""))
+	case cfURL:
+		p.writePlistValue(cfString(pval))
+	case cfUUID:
+		p.writePlistValue(cfString(UUID(pval).String()))
 	}
 }
 
+func (p *textPlistGenerator) PreserveKeyOrder() {
+	p.keepOrder = true
+}
+
+func (p *textPlistGenerator) DatePrecision(d time.Duration) {
+	p.precision = d
+}
+
+func (p *textPlistGenerator) PreserveFloat32() {
+	p.float32 = true
+}
+
+func (p *textPlistGenerator) Canonical() {
+	// With sorted keys, the output is already determined by the document alone.
+}
+
 func (p *textPlistGenerator) Indent(i string) {
 	p.indent = i
 	if i == "" {
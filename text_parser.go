@@ -13,7 +13,6 @@ import (
 	"io"
 	"runtime"
 	"strings"
-	"time"
 	"unicode/utf16"
 	"unicode/utf8"
 )
@@ -21,6 +20,9 @@ import (
 type textPlistParser struct {
 	reader io.Reader
 	format int
+	limits *limitTracker
+
+	preserveFloat32 bool
 
 	input string
 	start int
@@ -77,11 +79,9 @@ func (p *textPlistParser) parseDocument() (pval cfValue, parseError error) {
 		}
 	}()
 
-	buffer, err := io.ReadAll(p.reader)
-	if err != nil {
-		panic(err)
-	}
+	buffer := p.limits.readAll(p.reader)
 
+	var err error
 	p.input, err = guessEncodingAndConvert(buffer)
 	if err != nil {
 		panic(err)
@@ -99,6 +99,7 @@ func (p *textPlistParser) parseDocument() (pval cfValue, parseError error) {
 		// See -[NSDictionary propertyListFromStringsFileFormat:].
 		p.start = 0
 		p.pos = 0
+		p.limits.reset()
 		val = p.parseDictionary(true)
 	}
 
@@ -294,9 +295,11 @@ func (p *textPlistParser) parseQuotedString() cfString {
 			section := p.emit()
 			p.pos++ // skip "
 			if !slowPath {
+				p.limits.countBytes(uint64(len(section)))
 				return cfString(section)
 			} else {
 				s += section
+				p.limits.countBytes(uint64(len(s)))
 				return cfString(s)
 			}
 		case '\\':
@@ -314,6 +317,7 @@ func (p *textPlistParser) parseUnquotedString() cfString {
 	if s == "" {
 		p.error("invalid unquoted string (found an unquoted character that should be quoted?)")
 	}
+	p.limits.countBytes(uint64(len(s)))
 
 	return cfString(s)
 }
@@ -321,6 +325,8 @@ func (p *textPlistParser) parseUnquotedString() cfString {
 // the { has already been consumed
 func (p *textPlistParser) parseDictionary(ignoreEof bool) cfValue {
 	//p.ignore() // ignore the {
+	p.limits.enter()
+	defer p.limits.leave()
 	var keypv cfValue
 	keys := make([]string, 0, 32)
 	values := make([]cfValue, 0, 32)
@@ -368,6 +374,7 @@ outer:
 			p.error("missing = in dictionary")
 		}
 
+		p.limits.checkLength(uint64(len(keys) + 1))
 		keys = append(keys, string(keypv.(cfString)))
 		values = append(values, val)
 	}
@@ -379,6 +386,8 @@ outer:
 // the ( has already been consumed
 func (p *textPlistParser) parseArray() *cfArray {
 	//p.ignore() // ignore the (
+	p.limits.enter()
+	defer p.limits.leave()
 	values := make([]cfValue, 0, 32)
 outer:
 	for {
@@ -401,9 +410,10 @@ outer:
 			// TODO: Figure out why this was implemented.
 			continue
 		}
+		p.limits.checkLength(uint64(len(values) + 1))
 		values = append(values, pval)
 	}
-	return &cfArray{values}
+	return &cfArray{values: values}
 }
 
 // the <* have already been consumed
@@ -445,26 +455,23 @@ func (p *textPlistParser) parseGNUStepValue() cfValue {
 
 	switch typ {
 	case 'I':
-		if v[0] == '-' {
-			n := mustParseInt(v, 10, 64)
-			return &cfNumber{signed: true, value: uint64(n)}
-		} else {
-			n := mustParseUint(v, 10, 64)
-			return &cfNumber{signed: false, value: n}
-		}
+		return mustParseInteger(v, 10)
 	case 'R':
-		n := mustParseFloat(v, 64)
-		return &cfReal{wide: true, value: n} // TODO(DH) 32/64
+		r, err := parseReal(v, p.preserveFloat32)
+		if err != nil {
+			p.error(err.Error())
+		}
+		return r
 	case 'B':
 		b := v[0] == 'Y'
 		return cfBoolean(b)
 	case 'D':
-		t, err := time.Parse(textPlistTimeLayout, v)
+		t, err := parseTextDate(v)
 		if err != nil {
 			p.error(err.Error())
 		}
 
-		return cfDate(t.In(time.UTC))
+		return cfDate(t)
 	}
 	// We should never get here; we checked the type above
 	return nil
@@ -490,6 +497,7 @@ func (p *textPlistParser) parseGNUStepBase64() cfData {
 	if err != nil {
 		p.error("invalid GNUStep base64 data: " + err.Error())
 	}
+	p.limits.countBytes(uint64(len(data)))
 	return cfData(data)
 }
 
@@ -509,6 +517,7 @@ func (p *textPlistParser) parseHexData() cfData {
 				p.error("uneven number of hex digits in data")
 			}
 			p.ignore()
+			p.limits.countBytes(uint64(i))
 			return cfData(buf[:i])
 		// Apple and GNUstep both want these in pairs. We are a bit more lax.
 		// GS accepts comments too, but that seems like a lot of work.
@@ -540,6 +549,7 @@ func (p *textPlistParser) parseHexData() cfData {
 }
 
 func (p *textPlistParser) parsePlistValue() cfValue {
+	p.limits.countObject()
 	for {
 		p.skipWhitespaceAndComments()
 
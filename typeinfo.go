@@ -1,6 +1,7 @@
 package plist
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"sync"
@@ -9,6 +10,10 @@ import (
 // TypeInfo holds details for the plist representation of a type.
 type TypeInfo struct {
 	Fields []FieldInfo
+
+	// Remain is the field tagged ",remain", if any. It is a map with string keys
+	// that holds every dictionary key not matched by one of Fields.
+	Remain *FieldInfo
 }
 
 // FieldInfo holds details for the plist representation of a single field.
@@ -16,6 +21,14 @@ type FieldInfo struct {
 	idx       []int
 	Name      string
 	OmitEmpty bool
+	Required  bool
+
+	// Default holds the value of the "default=" tag option, if HasDefault is set.
+	// It is used in place of a missing key and converted like a string in a text property list.
+	Default    string
+	HasDefault bool
+
+	remain bool
 }
 
 var tinfoMap = &sync.Map{} //make(map[reflect.Type]*typeInfo)
@@ -52,6 +65,11 @@ func GetTypeInfo(typ reflect.Type) (*TypeInfo, error) {
 							return nil, err
 						}
 					}
+					if inner.Remain != nil && tinfo.Remain == nil {
+						remain := *inner.Remain
+						remain.idx = append([]int{i}, remain.idx...)
+						tinfo.Remain = &remain
+					}
 					continue
 				}
 			}
@@ -61,6 +79,17 @@ func GetTypeInfo(typ reflect.Type) (*TypeInfo, error) {
 				return nil, err
 			}
 
+			if finfo.remain {
+				if f.Type.Kind() != reflect.Map || f.Type.Key().Kind() != reflect.String {
+					return nil, fmt.Errorf("plist: remain field %s of %v must be a map with string keys", f.Name, typ)
+				}
+				if tinfo.Remain != nil && len(tinfo.Remain.idx) == 1 {
+					return nil, fmt.Errorf("plist: %v has more than one remain field", typ)
+				}
+				tinfo.Remain = finfo
+				continue
+			}
+
 			// Add the field if it doesn't conflict with other fields.
 			if err := addFieldInfo(typ, tinfo, finfo); err != nil {
 				return nil, err
@@ -84,9 +113,23 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*FieldInfo, erro
 			switch flag {
 			case "omitempty":
 				finfo.OmitEmpty = true
+			case "required":
+				finfo.Required = true
+			case "remain":
+				finfo.remain = true
+			default:
+				if def, ok := strings.CutPrefix(flag, "default="); ok {
+					finfo.Default, finfo.HasDefault = def, true
+				}
 			}
 		}
 	}
+	if finfo.HasDefault {
+		// Check the default now, rather than when a document happens to lack the key.
+		if err := unmarshalDefault(finfo, reflect.New(f.Type).Elem()); err != nil {
+			return nil, fmt.Errorf("plist: invalid default %q for field %s of %v: %v", finfo.Default, f.Name, typ, err)
+		}
+	}
 	if tag == "" {
 		// If the name part of the tag is completely empty,
 		// use the field name
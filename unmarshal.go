@@ -2,10 +2,14 @@ package plist
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,6 +22,89 @@ func (u *incompatibleDecodeTypeError) Error() string {
 	return fmt.Sprintf("plist: type mismatch: tried to decode plist type `%v' into value of type `%v'", u.src, u.dest)
 }
 
+type overflowDecodeError struct {
+	dest reflect.Type
+	num  string
+}
+
+func (u *overflowDecodeError) Error() string {
+	return fmt.Sprintf("plist: integer %s overflows value of type `%v'", u.num, u.dest)
+}
+
+// An UnknownKeyError is returned by a Decoder that disallows unknown keys
+// when a document contains keys that do not match any struct field.
+type UnknownKeyError struct {
+	// Paths holds the location of each unknown key, such as "Program.Arguments[2].Name".
+	Paths []string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if len(e.Paths) == 1 {
+		return fmt.Sprintf("plist: unknown key %q", e.Paths[0])
+	}
+	return fmt.Sprintf("plist: unknown keys %q", e.Paths)
+}
+
+// A MissingKeyError is returned when a document lacks keys for struct fields tagged "required".
+type MissingKeyError struct {
+	// Paths holds the location of each missing key, such as "Program.Arguments[2].Name".
+	Paths []string
+}
+
+func (e *MissingKeyError) Error() string {
+	if len(e.Paths) == 1 {
+		return fmt.Sprintf("plist: missing required key %q", e.Paths[0])
+	}
+	return fmt.Sprintf("plist: missing required keys %q", e.Paths)
+}
+
+// pathSegment is a dictionary key, or an array index if key is empty and index is non-negative.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// keyPath tracks the location of the value being unmarshaled, for use in error messages.
+type keyPath []pathSegment
+
+func (k *keyPath) pushKey(key string) {
+	*k = append(*k, pathSegment{key: key, index: -1})
+}
+
+func (k *keyPath) pushIndex(i int) {
+	*k = append(*k, pathSegment{index: i})
+}
+
+func (k *keyPath) pop() {
+	*k = (*k)[:len(*k)-1]
+}
+
+// String renders the path with dots between keys and indexes in brackets.
+func (k keyPath) String() string {
+	var b strings.Builder
+	for i, seg := range k {
+		if seg.index >= 0 {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(seg.index))
+			b.WriteByte(']')
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(seg.key)
+	}
+	return b.String()
+}
+
+// child renders the path of key within the current value.
+func (k keyPath) child(key string) string {
+	if len(k) == 0 {
+		return key
+	}
+	return k.String() + "." + key
+}
+
 var (
 	plistUnmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
@@ -58,14 +145,14 @@ func (p *Decoder) unmarshalTime(pval cfDate, val reflect.Value) {
 	val.Set(reflect.ValueOf(time.Time(pval)))
 }
 
-func (p *Decoder) unmarshalLaxString(s string, val reflect.Value) {
+func unmarshalLaxString(s string, val reflect.Value) {
 	switch val.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i := mustParseInt(s, 10, 64)
+		i := mustParseInt(s, 10, val.Type().Bits())
 		val.SetInt(i)
 		return
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		i := mustParseUint(s, 10, 64)
+		i := mustParseUint(s, 10, val.Type().Bits())
 		val.SetUint(i)
 		return
 	case reflect.Float32, reflect.Float64:
@@ -78,11 +165,11 @@ func (p *Decoder) unmarshalLaxString(s string, val reflect.Value) {
 		return
 	case reflect.Struct:
 		if val.Type() == timeType {
-			t, err := time.Parse(textPlistTimeLayout, s)
+			t, err := parseTextDate(s)
 			if err != nil {
 				panic(err)
 			}
-			val.Set(reflect.ValueOf(t.In(time.UTC)))
+			val.Set(reflect.ValueOf(t))
 			return
 		}
 		fallthrough
@@ -91,21 +178,103 @@ func (p *Decoder) unmarshalLaxString(s string, val reflect.Value) {
 	}
 }
 
+// unmarshalDefault sets val, the field described by finfo, to the field's default value.
+// The default is converted in the same way as a string in a text property list.
+// GetTypeInfo checks every default this way, so decoding never finds one invalid.
+func unmarshalDefault(finfo *FieldInfo, val reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.String {
+		val.SetString(finfo.Default)
+		return nil
+	}
+	unmarshalLaxString(finfo.Default, val)
+	return nil
+}
+
+// countExpanded records the creation of one more Go value for DecoderOptions.MaxExpandedObjects.
+// It is called for every value, so it also polls the decoder's context.
+func (p *Decoder) countExpanded() {
+	p.cancel.tick()
+	p.expanded++
+	if max := p.options.MaxExpandedObjects; max > 0 && p.expanded > max {
+		panic(&LimitError{"MaxExpandedObjects", int64(max)})
+	}
+}
+
+// findShared returns the value a shared container was previously unmarshaled into, if any.
+func (p *Decoder) findShared(pval cfValue, typ reflect.Type) (reflect.Value, bool) {
+	if !p.options.PreserveSharing {
+		return reflect.Value{}, false
+	}
+	v, ok := p.shared[sharedValue{pval, typ}]
+	return v, ok
+}
+
+func (p *Decoder) storeShared(pval cfValue, typ reflect.Type, v reflect.Value) {
+	if !p.options.PreserveSharing {
+		return
+	}
+	if p.shared == nil {
+		p.shared = make(map[sharedValue]reflect.Value)
+	}
+	p.shared[sharedValue{pval, typ}] = v
+}
+
 func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 	if pval == nil {
 		return
 	}
+	if _, ok := pval.(cfNull); ok {
+		// Like JSON's null, a null clears pointers, interfaces, maps and slices, and leaves anything else alone.
+		p.countExpanded()
+		switch val.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			val.Set(reflect.Zero(val.Type()))
+		}
+		return
+	}
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			val.Set(reflect.New(val.Type().Elem()))
 		}
 		val = val.Elem()
 	}
+	if val.Type() == rawValueType {
+		p.countExpanded()
+		val.Set(reflect.ValueOf(RawValue{pval: pval, lax: p.lax}))
+		return
+	}
+	if val.Type() == valueType {
+		// Values are mutable, so they must not share nodes with one another
+		// unless the caller asked for shared containers to be preserved.
+		if p.options.PreserveSharing {
+			p.countExpanded()
+		} else {
+			pval = cloneValue(pval, p.countExpanded)
+		}
+		val.Set(reflect.ValueOf(Value{pval}))
+		return
+	}
 	if isEmptyInterface(val) {
 		v := p.valueInterface(pval)
 		val.Set(reflect.ValueOf(v))
 		return
 	}
+	p.countExpanded()
 	incompatibleTypeError := &incompatibleDecodeTypeError{val.Type(), pval.typeName()}
 	// time.Time implements TextMarshaler, but we need to parse it as RFC3339
 	if date, ok := pval.(cfDate); ok {
@@ -119,6 +288,27 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 		p.unmarshalPlistInterface(pval, receiver.(Unmarshaler))
 		return
 	}
+	// UUID implements TextUnmarshaler, but a binary property list may also store it natively
+	if uuid, ok := pval.(cfUUID); ok {
+		switch {
+		case val.Type() == uuidType:
+			val.Set(reflect.ValueOf(UUID(uuid)))
+		case val.Kind() == reflect.String:
+			val.SetString(UUID(uuid).String())
+		default:
+			panic(incompatibleTypeError)
+		}
+		return
+	}
+	if val.Type() == urlType {
+		p.unmarshalURL(pval, val)
+		return
+	}
+	// big.Int implements TextUnmarshaler, but integers are not stored as text
+	if val.Type() == int128Type || val.Type() == bigIntType {
+		p.unmarshalInt128(pval, val)
+		return
+	}
 	if val.Type() != timeType {
 		if receiver, can := implementsInterface(val, textUnmarshalerType); can {
 			if str, ok := pval.(cfString); ok {
@@ -137,14 +327,14 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 			val.SetString(string(pval))
 			return
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			i, pe := strconv.ParseInt(string(pval), 10, 64)
+			i, pe := strconv.ParseInt(string(pval), 10, val.Type().Bits())
 			if pe != nil {
 				panic(pe)
 			}
 			val.SetInt(i)
 			return
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			i, pe := strconv.ParseUint(string(pval), 10, 64)
+			i, pe := strconv.ParseUint(string(pval), 10, val.Type().Bits())
 			if pe != nil {
 				panic(pe)
 			}
@@ -159,20 +349,28 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 			return
 		}
 		if p.lax {
-			p.unmarshalLaxString(string(pval), val)
+			unmarshalLaxString(string(pval), val)
 			return
 		}
 		panic(incompatibleTypeError)
 	case *cfNumber:
+		i := pval.int128()
 		switch val.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val.SetInt(int64(pval.value))
+			if !i.IsInt64() || val.OverflowInt(int64(i.Lo)) {
+				panic(&overflowDecodeError{val.Type(), pval.decimal()})
+			}
+			val.SetInt(int64(i.Lo))
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			val.SetUint(pval.value)
+			if !i.IsUint64() || val.OverflowUint(i.Lo) {
+				panic(&overflowDecodeError{val.Type(), pval.decimal()})
+			}
+			val.SetUint(i.Lo)
 		case reflect.Float32, reflect.Float64:
-			val.SetFloat(float64(pval.value))
+			f, _ := new(big.Float).SetInt(i.Big()).Float64()
+			val.SetFloat(f)
 		case reflect.String:
-			val.SetString(strconv.FormatUint(pval.value, 10))
+			val.SetString(pval.decimal())
 		default:
 			panic(incompatibleTypeError)
 		}
@@ -215,6 +413,11 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 			sval := reflect.ValueOf(b)
 			reflect.Copy(val, sval)
 		}
+	case cfURL:
+		if val.Kind() != reflect.String {
+			panic(incompatibleTypeError)
+		}
+		val.SetString(string(pval))
 	case cfUID:
 		if val.Type() == uidType {
 			val.SetUint(uint64(pval))
@@ -229,12 +432,69 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) {
 			}
 		}
 	case *cfArray:
+		if val.Kind() == reflect.Slice {
+			if shared, ok := p.findShared(pval, typ); ok {
+				val.Set(shared)
+				return
+			}
+			defer p.storeShared(pval, typ, val)
+		}
 		p.unmarshalArray(pval, val)
 	case *cfDictionary:
+		if typ == orderedDictionaryType {
+			p.unmarshalOrderedDictionary(pval, val)
+			return
+		}
+		if val.Kind() == reflect.Map {
+			if shared, ok := p.findShared(pval, typ); ok {
+				val.Set(shared)
+				return
+			}
+			defer p.storeShared(pval, typ, val)
+		}
 		p.unmarshalDictionary(pval, val)
 	}
 }
 
+// unmarshalInt128 unmarshals an integer into an Int128 or a big.Int.
+func (p *Decoder) unmarshalInt128(pval cfValue, val reflect.Value) {
+	var i Int128
+	switch pval := pval.(type) {
+	case *cfNumber:
+		i = pval.int128()
+	case cfString:
+		if !p.lax {
+			panic(&incompatibleDecodeTypeError{val.Type(), pval.typeName()})
+		}
+		i = mustParseInteger(string(pval), 10).int128()
+	default:
+		panic(&incompatibleDecodeTypeError{val.Type(), pval.typeName()})
+	}
+	if val.Type() == bigIntType {
+		val.Set(reflect.ValueOf(i.Big()).Elem())
+		return
+	}
+	val.Set(reflect.ValueOf(i))
+}
+
+// unmarshalURL unmarshals a URL, or a string holding one, into a url.URL.
+func (p *Decoder) unmarshalURL(pval cfValue, val reflect.Value) {
+	var s string
+	switch pval := pval.(type) {
+	case cfURL:
+		s = string(pval)
+	case cfString:
+		s = string(pval)
+	default:
+		panic(&incompatibleDecodeTypeError{val.Type(), pval.typeName()})
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	val.Set(reflect.ValueOf(*u))
+}
+
 func (p *Decoder) unmarshalArray(a *cfArray, val reflect.Value) {
 	var n int
 	if val.Kind() == reflect.Slice {
@@ -261,8 +521,10 @@ func (p *Decoder) unmarshalArray(a *cfArray, val reflect.Value) {
 	}
 
 	// Recur to read element into slice.
-	for _, sval := range a.values {
+	for i, sval := range a.values {
+		p.path.pushIndex(i)
 		p.unmarshal(sval, val.Index(n))
+		p.path.pop()
 		n++
 	}
 }
@@ -283,9 +545,54 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) {
 		}
 
 		for _, finfo := range tinfo.Fields {
+			if _, ok := entries[finfo.Name]; !ok {
+				if finfo.Required {
+					p.missing = append(p.missing, p.path.child(finfo.Name))
+				} else if finfo.HasDefault {
+					if err := unmarshalDefault(&finfo, finfo.Value(val)); err != nil {
+						panic(err)
+					}
+				}
+			}
+			p.path.pushKey(finfo.Name)
 			p.unmarshal(entries[finfo.Name], finfo.Value(val))
+			p.path.pop()
+			delete(entries, finfo.Name)
+		}
+
+		if tinfo.Remain != nil && len(entries) > 0 {
+			remain := tinfo.Remain.Value(val)
+			if remain.IsNil() {
+				remain.Set(reflect.MakeMap(remain.Type()))
+			}
+			for _, k := range dict.keys {
+				sval, ok := entries[k]
+				if !ok {
+					continue
+				}
+				keyv := reflect.ValueOf(k).Convert(remain.Type().Key())
+				elem := reflect.New(remain.Type().Elem()).Elem()
+				p.path.pushKey(k)
+				p.unmarshal(sval, elem)
+				p.path.pop()
+				remain.SetMapIndex(keyv, elem)
+			}
+			// The remain field has captured everything else, so nothing is unknown.
+			clear(entries)
+		}
+
+		if p.disallowUnknownKeys {
+			// Report the keys in document order.
+			for _, k := range dict.keys {
+				if _, ok := entries[k]; ok {
+					p.unknown = append(p.unknown, p.path.child(k))
+				}
+			}
 		}
 	case reflect.Map:
+		if !isMapKeyDecodeType(typ.Key()) {
+			panic(&incompatibleDecodeTypeError{typ, dict.typeName()})
+		}
 		if val.IsNil() {
 			val.Set(reflect.MakeMap(typ))
 		}
@@ -293,10 +600,12 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) {
 		for i, k := range dict.keys {
 			sval := dict.values[i]
 
-			keyv := reflect.ValueOf(k).Convert(typ.Key())
+			keyv := mapKeyValue(k, typ.Key())
 			mapElem := reflect.New(typ.Elem()).Elem()
 
+			p.path.pushKey(k)
 			p.unmarshal(sval, mapElem)
+			p.path.pop()
 			val.SetMapIndex(keyv, mapElem)
 		}
 	default:
@@ -304,12 +613,81 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) {
 	}
 }
 
+// isMapKeyDecodeType reports whether dictionaries can be decoded into maps with keys of type typ.
+// It accepts the types isMapKeyType does, with encoding.TextUnmarshaler in place of encoding.TextMarshaler,
+// and interfaces that can hold a string.
+func isMapKeyDecodeType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Bool:
+		return true
+	case reflect.Interface:
+		return reflect.TypeOf("").ConvertibleTo(typ)
+	}
+	return reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+// mapKeyValue converts a dictionary key to a map key of type typ, undoing mapKeyString.
+func mapKeyValue(key string, typ reflect.Type) reflect.Value {
+	if typ.Kind() == reflect.String || typ.Kind() == reflect.Interface {
+		return reflect.ValueOf(key).Convert(typ)
+	}
+	keyv := reflect.New(typ)
+	if tu, ok := keyv.Interface().(encoding.TextUnmarshaler); ok {
+		if err := tu.UnmarshalText([]byte(key)); err != nil {
+			panic(err)
+		}
+		return keyv.Elem()
+	}
+
+	var err error
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(key, 10, typ.Bits()); err == nil {
+			keyv.Elem().SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, err = strconv.ParseUint(key, 10, typ.Bits()); err == nil {
+			keyv.Elem().SetUint(u)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(key); err == nil {
+			keyv.Elem().SetBool(b)
+		}
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		panic(&overflowDecodeError{typ, key})
+	} else if err != nil {
+		panic(&incompatibleDecodeTypeError{typ, "key " + strconv.Quote(key)})
+	}
+	return keyv.Elem()
+}
+
+func (p *Decoder) unmarshalOrderedDictionary(dict *cfDictionary, val reflect.Value) {
+	saved := p.ordered
+	p.ordered = true
+	defer func() { p.ordered = saved }()
+
+	val.Set(reflect.ValueOf(p.dictionaryInterface(dict)))
+}
+
 /* *Interface is modelled after encoding/json */
 func (p *Decoder) valueInterface(pval cfValue) any {
+	p.countExpanded()
 	switch pval := pval.(type) {
+	case cfNull:
+		return nil
 	case cfString:
 		return string(pval)
 	case *cfNumber:
+		if pval.hi != 0 {
+			return pval.int128()
+		}
 		if pval.signed {
 			return int64(pval.value)
 		}
@@ -323,28 +701,60 @@ func (p *Decoder) valueInterface(pval cfValue) any {
 	case cfBoolean:
 		return bool(pval)
 	case *cfArray:
-		return p.arrayInterface(pval)
+		if shared, ok := p.findShared(pval, nil); ok {
+			return shared.Interface()
+		}
+		v := p.arrayInterface(pval)
+		p.storeShared(pval, nil, reflect.ValueOf(v))
+		return v
 	case *cfDictionary:
-		return p.dictionaryInterface(pval)
+		var typ reflect.Type
+		if p.ordered {
+			typ = orderedDictionaryType
+		}
+		if shared, ok := p.findShared(pval, typ); ok {
+			return shared.Interface()
+		}
+		v := p.dictionaryInterface(pval)
+		p.storeShared(pval, typ, reflect.ValueOf(v))
+		return v
 	case cfData:
 		return []byte(pval)
 	case cfDate:
 		return time.Time(pval)
 	case cfUID:
 		return UID(pval)
+	case cfURL:
+		u, _ := url.Parse(string(pval))
+		return u
+	case cfUUID:
+		return UUID(pval)
 	}
 	return nil
 }
 
-func (p *Decoder) arrayInterface(a *cfArray) []any {
+func (p *Decoder) arrayInterface(a *cfArray) any {
 	out := make([]any, len(a.values))
 	for i, subv := range a.values {
 		out[i] = p.valueInterface(subv)
 	}
+	switch a.set {
+	case cfUnorderedSet:
+		return Set(out)
+	case cfOrderedSet:
+		return OrderedSet(out)
+	}
 	return out
 }
 
-func (p *Decoder) dictionaryInterface(dict *cfDictionary) map[string]any {
+func (p *Decoder) dictionaryInterface(dict *cfDictionary) any {
+	if p.ordered {
+		out := make(OrderedDictionary, len(dict.keys))
+		for i, k := range dict.keys {
+			out[i] = DictionaryEntry{k, p.valueInterface(dict.values[i])}
+		}
+		return out
+	}
 	out := make(map[string]any)
 	for i, k := range dict.keys {
 		subv := dict.values[i]
@@ -0,0 +1,521 @@
+package plist
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// A Kind is the type of property list value held by a Value.
+type Kind int
+
+const (
+	InvalidKind Kind = iota
+	StringKind
+	IntegerKind
+	RealKind
+	BooleanKind
+	DataKind
+	DateKind
+	ArrayKind
+	DictionaryKind
+	UIDKind
+	NullKind
+	URLKind
+	UUIDKind
+	SetKind        // the unordered set of a binary property list; its members are held in document order
+	OrderedSetKind // the ordered set of a binary property list
+)
+
+var kindNames = map[Kind]string{
+	InvalidKind:    "invalid",
+	StringKind:     "string",
+	IntegerKind:    "integer",
+	RealKind:       "real",
+	BooleanKind:    "boolean",
+	DataKind:       "data",
+	DateKind:       "date",
+	ArrayKind:      "array",
+	DictionaryKind: "dictionary",
+	UIDKind:        "UID",
+	NullKind:       "null",
+	URLKind:        "URL",
+	UUIDKind:       "UUID",
+	SetKind:        "set",
+	OrderedSetKind: "ordered set",
+}
+
+// isArray reports whether k is an array or a set, which share the methods of arrays.
+func (k Kind) isArray() bool {
+	return k == ArrayKind || k == SetKind || k == OrderedSetKind
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Value is a node in a property list tree.
+//
+// Unlike the Go values produced by decoding into an empty interface, a Value keeps
+// every distinction a property list makes: signed and unsigned integers, 32- and 64-bit
+// reals, UIDs, and the order of dictionary keys.
+//
+// A Decoder can decode into a Value, and an Encoder can encode one directly.
+// Arrays and dictionaries are references, like Go maps: copies of a Value share
+// their contents, and mutating one is visible through the others. Use Clone for
+// an independent copy.
+//
+// The zero Value is invalid; it is omitted when encoding a struct or map.
+type Value struct {
+	pval cfValue
+}
+
+var valueType = reflect.TypeOf(Value{})
+
+// NewString returns a string Value.
+func NewString(s string) Value {
+	return Value{cfString(s)}
+}
+
+// NewInt returns a signed integer Value.
+func NewInt(i int64) Value {
+	return Value{&cfNumber{signed: true, value: uint64(i)}}
+}
+
+// NewUint returns an unsigned integer Value.
+func NewUint(i uint64) Value {
+	return Value{&cfNumber{signed: false, value: i}}
+}
+
+// NewInt128 returns an integer Value of up to 128 bits.
+func NewInt128(i Int128) Value {
+	return Value{newInt128Number(i)}
+}
+
+// NewReal returns a 64-bit real Value.
+func NewReal(f float64) Value {
+	return Value{&cfReal{wide: true, value: f}}
+}
+
+// NewReal32 returns a 32-bit real Value.
+func NewReal32(f float32) Value {
+	return Value{&cfReal{wide: false, value: float64(f)}}
+}
+
+// NewBool returns a boolean Value.
+func NewBool(b bool) Value {
+	return Value{cfBoolean(b)}
+}
+
+// NewData returns a data Value. The Value refers to b; it does not copy it.
+func NewData(b []byte) Value {
+	return Value{cfData(b)}
+}
+
+// NewDate returns a date Value.
+func NewDate(t time.Time) Value {
+	return Value{cfDate(t)}
+}
+
+// NewUID returns a UID Value, as used by keyed archives.
+func NewUID(u UID) Value {
+	return Value{cfUID(u)}
+}
+
+// NewNull returns a null Value. Only binary property lists can store a null;
+// in XML and text property lists it is written as an empty string.
+func NewNull() Value {
+	return Value{cfNull{}}
+}
+
+// NewURL returns a URL Value.
+func NewURL(u *url.URL) Value {
+	return Value{cfURL(u.String())}
+}
+
+// NewUUID returns a UUID Value.
+func NewUUID(u UUID) Value {
+	return Value{cfUUID(u)}
+}
+
+// NewArray returns an array Value holding values.
+func NewArray(values ...Value) Value {
+	arr := &cfArray{values: make([]cfValue, 0, len(values))}
+	for _, v := range values {
+		arr.values = append(arr.values, v.mustBeValid())
+	}
+	return Value{arr}
+}
+
+// NewSet returns a set Value holding values, as in a binary property list.
+func NewSet(values ...Value) Value {
+	v := NewArray(values...)
+	v.pval.(*cfArray).set = cfUnorderedSet
+	return v
+}
+
+// NewOrderedSet returns an ordered set Value holding values, as in a binary property list.
+func NewOrderedSet(values ...Value) Value {
+	v := NewArray(values...)
+	v.pval.(*cfArray).set = cfOrderedSet
+	return v
+}
+
+// NewDictionary returns an empty dictionary Value.
+func NewDictionary() Value {
+	return Value{&cfDictionary{}}
+}
+
+// ValueOf returns the Value that x would be encoded as.
+func ValueOf(x any) (val Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+
+	pval := (&Encoder{}).marshal(reflect.ValueOf(x))
+	if pval == nil {
+		return Value{}, fmt.Errorf("plist: %v has no property list representation", x)
+	}
+	return Value{pval}, nil
+}
+
+// Unmarshal decodes the Value into v, following the same rules as a Decoder.
+func (v Value) Unmarshal(x any) error {
+	p := &Decoder{Format: InvalidFormat}
+	return p.unmarshalDocument(v.pval, reflect.ValueOf(x))
+}
+
+// Interface returns the Value as the Go value Unmarshal would produce for an empty interface.
+func (v Value) Interface() any {
+	if v.pval == nil {
+		return nil
+	}
+	return (&Decoder{}).valueInterface(v.pval)
+}
+
+// IsValid reports whether v holds a value.
+func (v Value) IsValid() bool {
+	return v.pval != nil
+}
+
+// Kind returns the type of v.
+func (v Value) Kind() Kind {
+	switch pval := v.pval.(type) {
+	case cfString:
+		return StringKind
+	case *cfNumber:
+		return IntegerKind
+	case *cfReal:
+		return RealKind
+	case cfBoolean:
+		return BooleanKind
+	case cfData:
+		return DataKind
+	case cfDate:
+		return DateKind
+	case *cfArray:
+		switch pval.set {
+		case cfUnorderedSet:
+			return SetKind
+		case cfOrderedSet:
+			return OrderedSetKind
+		}
+		return ArrayKind
+	case *cfDictionary:
+		return DictionaryKind
+	case cfUID:
+		return UIDKind
+	case cfNull:
+		return NullKind
+	case cfURL:
+		return URLKind
+	case cfUUID:
+		return UUIDKind
+	}
+	return InvalidKind
+}
+
+// AsString returns v's value if it is a string.
+func (v Value) AsString() (string, bool) {
+	s, ok := v.pval.(cfString)
+	return string(s), ok
+}
+
+// AsInt returns v's value if it is an integer that fits in an int64.
+func (v Value) AsInt() (int64, bool) {
+	n, ok := v.pval.(*cfNumber)
+	if !ok || n.hi != 0 || (!n.signed && n.value > math.MaxInt64) {
+		return 0, false
+	}
+	return int64(n.value), true
+}
+
+// AsUint returns v's value if it is a non-negative integer.
+func (v Value) AsUint() (uint64, bool) {
+	n, ok := v.pval.(*cfNumber)
+	if !ok || n.hi != 0 || (n.signed && int64(n.value) < 0) {
+		return 0, false
+	}
+	return n.value, true
+}
+
+// AsInt128 returns v's value if it is an integer.
+func (v Value) AsInt128() (Int128, bool) {
+	n, ok := v.pval.(*cfNumber)
+	if !ok {
+		return Int128{}, false
+	}
+	return n.int128(), true
+}
+
+// IsSigned reports whether v is an integer that was stored as signed.
+func (v Value) IsSigned() bool {
+	n, ok := v.pval.(*cfNumber)
+	return ok && n.signed
+}
+
+// AsFloat returns v's value if it is a real.
+func (v Value) AsFloat() (float64, bool) {
+	r, ok := v.pval.(*cfReal)
+	if !ok {
+		return 0, false
+	}
+	return r.value, true
+}
+
+// IsWide reports whether v is a 64-bit real.
+func (v Value) IsWide() bool {
+	r, ok := v.pval.(*cfReal)
+	return ok && r.wide
+}
+
+// AsBool returns v's value if it is a boolean.
+func (v Value) AsBool() (bool, bool) {
+	b, ok := v.pval.(cfBoolean)
+	return bool(b), ok
+}
+
+// AsData returns v's value if it is data. The returned slice is not a copy.
+func (v Value) AsData() ([]byte, bool) {
+	d, ok := v.pval.(cfData)
+	return []byte(d), ok
+}
+
+// AsDate returns v's value if it is a date.
+func (v Value) AsDate() (time.Time, bool) {
+	d, ok := v.pval.(cfDate)
+	return time.Time(d), ok
+}
+
+// AsUID returns v's value if it is a UID.
+func (v Value) AsUID() (UID, bool) {
+	u, ok := v.pval.(cfUID)
+	return UID(u), ok
+}
+
+// AsURL returns v's value if it is a URL.
+func (v Value) AsURL() (*url.URL, bool) {
+	s, ok := v.pval.(cfURL)
+	if !ok {
+		return nil, false
+	}
+	u, err := url.Parse(string(s))
+	return u, err == nil
+}
+
+// AsUUID returns v's value if it is a UUID.
+func (v Value) AsUUID() (UUID, bool) {
+	u, ok := v.pval.(cfUUID)
+	return UUID(u), ok
+}
+
+// Len returns the number of elements in an array or set, or entries in a dictionary, and 0 for anything else.
+func (v Value) Len() int {
+	switch pval := v.pval.(type) {
+	case *cfArray:
+		return len(pval.values)
+	case *cfDictionary:
+		return len(pval.keys)
+	}
+	return 0
+}
+
+// Index returns the i'th element of an array or set, or an invalid Value if there is no such element.
+func (v Value) Index(i int) Value {
+	arr, ok := v.pval.(*cfArray)
+	if !ok || i < 0 || i >= len(arr.values) {
+		return Value{}
+	}
+	return Value{arr.values[i]}
+}
+
+// Keys returns the keys of a dictionary in order.
+func (v Value) Keys() []string {
+	dict, ok := v.pval.(*cfDictionary)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), dict.keys...)
+}
+
+// Lookup returns the value stored in a dictionary under key.
+func (v Value) Lookup(key string) (Value, bool) {
+	dict, ok := v.pval.(*cfDictionary)
+	if !ok {
+		return Value{}, false
+	}
+	if i := dict.index(key); i >= 0 {
+		return Value{dict.values[i]}, true
+	}
+	return Value{}, false
+}
+
+// Set stores val in a dictionary under key. A new key is added after the existing ones.
+// It panics if v is not a dictionary or val is invalid.
+func (v Value) Set(key string, val Value) {
+	dict := v.mustBe(DictionaryKind, "Set").(*cfDictionary)
+	pval := val.mustBeValid()
+	if i := dict.index(key); i >= 0 {
+		dict.values[i] = pval
+		return
+	}
+	dict.keys = append(dict.keys, key)
+	dict.values = append(dict.values, pval)
+}
+
+// Delete removes key from a dictionary, and reports whether it was present.
+// It panics if v is not a dictionary.
+func (v Value) Delete(key string) bool {
+	dict := v.mustBe(DictionaryKind, "Delete").(*cfDictionary)
+	i := dict.index(key)
+	if i < 0 {
+		return false
+	}
+	dict.keys = append(dict.keys[:i], dict.keys[i+1:]...)
+	dict.values = append(dict.values[:i], dict.values[i+1:]...)
+	return true
+}
+
+// SetIndex replaces the i'th element of an array or set.
+// It panics if v is not an array or set, i is out of range, or val is invalid.
+func (v Value) SetIndex(i int, val Value) {
+	arr := v.mustBeArray("SetIndex")
+	if i < 0 || i >= len(arr.values) {
+		panic(fmt.Sprintf("plist: SetIndex: index %d out of range [0:%d]", i, len(arr.values)))
+	}
+	arr.values[i] = val.mustBeValid()
+}
+
+// Append adds values to the end of an array or set.
+// It panics if v is not an array or set, or if any of values is invalid.
+func (v Value) Append(values ...Value) {
+	arr := v.mustBeArray("Append")
+	for _, val := range values {
+		arr.values = append(arr.values, val.mustBeValid())
+	}
+}
+
+// Insert adds val to an array or set so that it becomes the i'th element; i may be v.Len().
+// It panics if v is not an array or set, i is out of range, or val is invalid.
+func (v Value) Insert(i int, val Value) {
+	arr := v.mustBeArray("Insert")
+	if i < 0 || i > len(arr.values) {
+		panic(fmt.Sprintf("plist: Insert: index %d out of range [0:%d]", i, len(arr.values)))
+	}
+	pval := val.mustBeValid()
+	arr.values = append(arr.values, nil)
+	copy(arr.values[i+1:], arr.values[i:])
+	arr.values[i] = pval
+}
+
+// DeleteIndex removes the i'th element of an array or set.
+// It panics if v is not an array or set, or if i is out of range.
+func (v Value) DeleteIndex(i int) {
+	arr := v.mustBeArray("DeleteIndex")
+	if i < 0 || i >= len(arr.values) {
+		panic(fmt.Sprintf("plist: DeleteIndex: index %d out of range [0:%d]", i, len(arr.values)))
+	}
+	arr.values = append(arr.values[:i], arr.values[i+1:]...)
+}
+
+// Clone returns a deep copy of v.
+func (v Value) Clone() Value {
+	return Value{cloneValue(v.pval, nil)}
+}
+
+func (v Value) mustBe(kind Kind, method string) cfValue {
+	if k := v.Kind(); k != kind {
+		panic(fmt.Sprintf("plist: %s called on %v Value", method, k))
+	}
+	return v.pval
+}
+
+// mustBeArray is mustBe for the methods that arrays share with sets.
+func (v Value) mustBeArray(method string) *cfArray {
+	if k := v.Kind(); !k.isArray() {
+		panic(fmt.Sprintf("plist: %s called on %v Value", method, k))
+	}
+	return v.pval.(*cfArray)
+}
+
+func (v Value) mustBeValid() cfValue {
+	if v.pval == nil {
+		panic("plist: use of invalid Value")
+	}
+	return v.pval
+}
+
+// index returns the position of key in the dictionary, or -1.
+func (p *cfDictionary) index(key string) int {
+	for i, k := range p.keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneValue returns a deep copy of pval, calling visit (if set) for every value copied.
+// Values shared within a binary property list are copied separately for every reference.
+func cloneValue(pval cfValue, visit func()) cfValue {
+	if visit != nil {
+		visit()
+	}
+	switch pval := pval.(type) {
+	case *cfArray:
+		arr := &cfArray{values: make([]cfValue, len(pval.values)), set: pval.set}
+		for i, subv := range pval.values {
+			arr.values[i] = cloneValue(subv, visit)
+		}
+		return arr
+	case *cfDictionary:
+		dict := &cfDictionary{
+			keys:   append([]string(nil), pval.keys...),
+			values: make([]cfValue, len(pval.values)),
+		}
+		for i, subv := range pval.values {
+			dict.values[i] = cloneValue(subv, visit)
+		}
+		return dict
+	case *cfNumber:
+		n := *pval
+		return &n
+	case *cfReal:
+		r := *pval
+		return &r
+	case cfData:
+		return append(cfData(nil), pval...)
+	}
+	return pval
+}
@@ -0,0 +1,180 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// MarshalJSON encodes v as JSON. Dictionaries keep their key order.
+//
+// JSON cannot represent every property list type: data is encoded as a base64 string,
+// a date as an RFC 3339 string, and a UID as {"CF$UID": n}, as in an XML property list.
+// Reals that are infinite or NaN cannot be encoded at all.
+func (v Value) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONValue(&buf, v.pval); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, pval cfValue) error {
+	switch pval := pval.(type) {
+	case nil, cfNull:
+		buf.WriteString("null")
+	case cfString:
+		b, _ := json.Marshal(string(pval))
+		buf.Write(b)
+	case *cfNumber:
+		buf.WriteString(pval.decimal())
+	case *cfReal:
+		if math.IsInf(pval.value, 0) || math.IsNaN(pval.value) {
+			return fmt.Errorf("plist: cannot encode real %v as JSON", pval.value)
+		}
+		bits := 64
+		if !pval.wide {
+			bits = 32
+		}
+		b := strconv.AppendFloat(nil, pval.value, 'g', -1, bits)
+		if bytes.IndexAny(b, ".eE") < 0 {
+			// Keep reals recognizable as reals when the JSON is read back.
+			b = append(b, ".0"...)
+		}
+		buf.Write(b)
+	case cfBoolean:
+		buf.WriteString(strconv.FormatBool(bool(pval)))
+	case cfData:
+		b, _ := json.Marshal([]byte(pval))
+		buf.Write(b)
+	case cfDate:
+		b, err := time.Time(pval).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case cfUID:
+		return writeJSONValue(buf, pval.toDict())
+	case cfURL:
+		return writeJSONValue(buf, cfString(pval))
+	case cfUUID:
+		return writeJSONValue(buf, cfString(UUID(pval).String()))
+	case *cfArray:
+		buf.WriteByte('[')
+		for i, subv := range pval.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, subv); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *cfDictionary:
+		buf.WriteByte('{')
+		for i, k := range pval.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			b, _ := json.Marshal(k)
+			buf.Write(b)
+			buf.WriteByte(':')
+			if err := writeJSONValue(buf, pval.values[i]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes JSON into v, keeping the order of object keys.
+//
+// Numbers without a fraction or exponent become integers, and other numbers 64-bit reals.
+// Objects of the form {"CF$UID": n} become UIDs, and JSON null becomes a null Value (see NewNull),
+// wherever it appears, so that MarshalJSON and UnmarshalJSON round-trip.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	pval, err := readJSONValue(dec)
+	if err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err == nil {
+		return errors.New("plist: unexpected data after JSON value")
+	}
+	v.pval = pval
+	return nil
+}
+
+func readJSONValue(dec *json.Decoder) (cfValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return cfNull{}, nil
+	case string:
+		return cfString(tok), nil
+	case bool:
+		return cfBoolean(tok), nil
+	case json.Number:
+		s := tok.String()
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &cfNumber{signed: true, value: uint64(i)}, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return &cfNumber{signed: false, value: u}, nil
+		}
+		if b, ok := new(big.Int).SetString(s, 10); ok {
+			if i, ok := Int128FromBig(b); ok {
+				return newInt128Number(i), nil
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid JSON number %s", s)
+		}
+		return &cfReal{wide: true, value: f}, nil
+	case json.Delim:
+		switch tok {
+		case '[':
+			arr := &cfArray{}
+			for dec.More() {
+				subv, err := readJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr.values = append(arr.values, subv)
+			}
+			_, err := dec.Token()
+			return arr, err
+		case '{':
+			dict := &cfDictionary{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				subv, err := readJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				dict.keys = append(dict.keys, key.(string))
+				dict.values = append(dict.values, subv)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return dict.maybeUID(false), nil
+		}
+	}
+	return nil, fmt.Errorf("plist: unexpected JSON token %v", tok)
+}
@@ -0,0 +1,191 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValueDecodePreservesDistinctions(t *testing.T) {
+	doc, err := Marshal(map[string]any{
+		"signed":   int64(-1),
+		"unsigned": uint64(1 << 63),
+		"small":    float32(0.5),
+		"wide":     0.25,
+		"uid":      UID(3),
+		"count":    uint64(3),
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root Value
+	if _, err := Unmarshal(doc, &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Kind() != DictionaryKind || root.Len() != 6 {
+		t.Fatalf("expected a dictionary with 6 entries, got %v with %d", root.Kind(), root.Len())
+	}
+
+	signed, _ := root.Lookup("signed")
+	if i, ok := signed.AsInt(); !ok || i != -1 || !signed.IsSigned() {
+		t.Errorf("expected signed integer -1, got %v (signed: %v)", i, signed.IsSigned())
+	}
+	if _, ok := signed.AsUint(); ok {
+		t.Error("expected a negative integer not to be returned as unsigned")
+	}
+
+	unsigned, _ := root.Lookup("unsigned")
+	if u, ok := unsigned.AsUint(); !ok || u != 1<<63 || unsigned.IsSigned() {
+		t.Errorf("expected unsigned integer 1<<63, got %v", u)
+	}
+	if _, ok := unsigned.AsInt(); ok {
+		t.Error("expected 1<<63 not to be returned as an int64")
+	}
+
+	small, _ := root.Lookup("small")
+	wide, _ := root.Lookup("wide")
+	if small.IsWide() || !wide.IsWide() {
+		t.Error("expected real widths to be preserved")
+	}
+
+	uid, _ := root.Lookup("uid")
+	count, _ := root.Lookup("count")
+	if uid.Kind() != UIDKind || count.Kind() != IntegerKind {
+		t.Errorf("expected UID and integer to stay distinct, got %v and %v", uid.Kind(), count.Kind())
+	}
+}
+
+func TestValueKeyOrder(t *testing.T) {
+	var root Value
+	if _, err := Unmarshal([]byte(`{zebra = 1; apple = 2; mango = 3;}`), &root); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"zebra", "apple", "mango"}
+	if keys := root.Keys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v in document order, got %v", expected, keys)
+	}
+}
+
+func TestValueBuildAndEncode(t *testing.T) {
+	date := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	root := NewDictionary()
+	root.Set("name", NewString("example"))
+	root.Set("list", NewArray(NewInt(1), NewUint(2)))
+	root.Set("ratio", NewReal32(0.5))
+	root.Set("when", NewDate(date))
+	root.Set("blob", NewData([]byte{1, 2}))
+	root.Set("ok", NewBool(true))
+	root.Set("removed", NewBool(false))
+
+	list, _ := root.Lookup("list")
+	list.Append(NewString("end"))
+	list.Insert(0, NewString("start"))
+	list.SetIndex(1, NewInt(-1))
+	list.DeleteIndex(2)
+	if !root.Delete("removed") || root.Delete("removed") {
+		t.Error("expected Delete to report whether the key was present")
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(root, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var val any
+			if _, err := Unmarshal(doc, &val); err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]any{
+				"name":  "example",
+				"list":  []any{"start", int64(-1), "end"},
+				"ratio": float32(0.5),
+				"when":  date,
+				"blob":  []byte{1, 2},
+				"ok":    true,
+			}
+			if format != BinaryFormat {
+				// Only binary property lists record the width of a real.
+				expected["ratio"] = 0.5
+			}
+			if !reflect.DeepEqual(val, expected) {
+				t.Errorf("expected %#v, got %#v", expected, val)
+			}
+		})
+	}
+
+	var decoded struct {
+		Name string `plist:"name"`
+		List []any  `plist:"list"`
+	}
+	if err := root.Unmarshal(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "example" || len(decoded.List) != 3 {
+		t.Errorf("unexpected result from Value.Unmarshal: %+v", decoded)
+	}
+}
+
+func TestValueJSON(t *testing.T) {
+	root := NewDictionary()
+	root.Set("name", NewString("example"))
+	root.Set("gone", NewNull())
+	root.Set("list", NewArray(NewInt(-1), NewNull(), NewReal(0.5), NewUID(3)))
+
+	for _, val := range []Value{root, NewNull()} {
+		doc, err := json.Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Value
+		if err := json.Unmarshal(doc, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(val) {
+			t.Errorf("expected %s to decode to %v, got %v", doc, val.Interface(), decoded.Interface())
+		}
+	}
+
+	if doc, _ := json.Marshal(root); string(doc) != `{"name":"example","gone":null,"list":[-1,null,0.5,{"CF$UID":3}]}` {
+		t.Errorf("unexpected JSON %s", doc)
+	}
+}
+
+func TestValueClone(t *testing.T) {
+	original, err := ValueOf(map[string]any{"list": []any{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := original.Clone()
+	list, _ := clone.Lookup("list")
+	list.Append(NewString("b"))
+
+	origList, _ := original.Lookup("list")
+	if origList.Len() != 1 || list.Len() != 2 {
+		t.Errorf("expected the clone to be independent of the original")
+	}
+}
+
+func TestValueDecodeUnsharesBinaryObjects(t *testing.T) {
+	var root Value
+	if err := NewDecoder(bytes.NewReader(sharedArrayBplist(2))).Decode(&root); err != nil {
+		t.Fatal(err)
+	}
+	root.Index(0).Append(NewString("extra"))
+	if root.Index(0).Len() == root.Index(1).Len() {
+		t.Error("expected shared binary objects to decode into independent Values")
+	}
+
+	decoder := NewDecoder(bytes.NewReader(sharedArrayBplist(2)))
+	decoder.SetOptions(DecoderOptions{PreserveSharing: true})
+	if err := decoder.Decode(&root); err != nil {
+		t.Fatal(err)
+	}
+	root.Index(0).Append(NewString("extra"))
+	if root.Index(0).Len() != root.Index(1).Len() {
+		t.Error("expected shared binary objects to stay shared with PreserveSharing")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"strconv"
 	"time"
 )
 
@@ -27,16 +26,17 @@ const (
 	xmlTrueTag           = "true"
 )
 
-func formatXMLFloat(f float64) string {
+// formatXMLFloat formats a real for an XML property list, spelling non-finite reals as CoreFoundation does.
+func formatXMLFloat(r *cfReal, preserveFloat32 bool) string {
 	switch {
-	case math.IsInf(f, 1):
-		return "inf"
-	case math.IsInf(f, -1):
-		return "-inf"
-	case math.IsNaN(f):
+	case math.IsInf(r.value, 1):
+		return "+infinity"
+	case math.IsInf(r.value, -1):
+		return "-infinity"
+	case math.IsNaN(r.value):
 		return "nan"
 	}
-	return strconv.FormatFloat(f, 'g', -1, 64)
+	return formatReal(r, preserveFloat32)
 }
 
 type xmlPlistGenerator struct {
@@ -45,12 +45,31 @@ type xmlPlistGenerator struct {
 	indent     string
 	depth      int
 	putNewline bool
+	keepOrder  bool
+	precision  time.Duration
+	float32    bool
 }
 
 func (p *xmlPlistGenerator) Indent(i string) {
 	p.indent = i
 }
 
+func (p *xmlPlistGenerator) PreserveKeyOrder() {
+	p.keepOrder = true
+}
+
+func (p *xmlPlistGenerator) DatePrecision(d time.Duration) {
+	p.precision = d
+}
+
+func (p *xmlPlistGenerator) PreserveFloat32() {
+	p.float32 = true
+}
+
+func (p *xmlPlistGenerator) Canonical() {
+	// With sorted keys, the output is already determined by the document alone.
+}
+
 func (p *xmlPlistGenerator) writeIndent() {
 	for i := 0; i < p.depth; i++ {
 		p.WriteString(p.indent)
@@ -82,7 +101,9 @@ func (p *xmlPlistGenerator) element(key string, value string) {
 }
 
 func (p *xmlPlistGenerator) writeDictionary(dict *cfDictionary) {
-	dict.sort()
+	if !p.keepOrder {
+		dict = dict.sorted()
+	}
 	if len(dict.keys) == 0 {
 		p.writeIndent()
 		p.WriteString(fmt.Sprintf("<%s/>\n", xmlDictTag))
@@ -127,13 +148,9 @@ func (p *xmlPlistGenerator) writePlistValue(pval cfValue) {
 	case cfString:
 		p.element(xmlStringTag, string(pval))
 	case *cfNumber:
-		if pval.signed {
-			p.element(xmlIntegerTag, strconv.FormatInt(int64(pval.value), 10))
-		} else {
-			p.element(xmlIntegerTag, strconv.FormatUint(pval.value, 10))
-		}
+		p.element(xmlIntegerTag, pval.decimal())
 	case *cfReal:
-		p.element(xmlRealTag, formatXMLFloat(pval.value))
+		p.element(xmlRealTag, formatXMLFloat(pval, p.float32))
 	case cfBoolean:
 		if bool(pval) {
 			p.element(xmlTrueTag, "")
@@ -161,13 +178,20 @@ func (p *xmlPlistGenerator) writePlistValue(pval cfValue) {
 			p.element(xmlDataTag, dataBase64)
 		}
 	case cfDate:
-		p.element(xmlDateTag, time.Time(pval).In(time.UTC).Format(time.RFC3339))
+		p.element(xmlDateTag, time.Time(pval).Truncate(p.precision).In(time.UTC).Format(xmlPlistTimeLayout))
 	case *cfDictionary:
 		p.writeDictionary(pval)
 	case *cfArray:
 		p.writeArray(pval)
 	case cfUID:
 		p.writePlistValue(pval.toDict())
+	case cfNull:
+		// XML has no null; an empty string stands in for it.
+		p.writePlistValue(cfString(""))
+	case cfURL:
+		p.writePlistValue(cfString(pval))
+	case cfUUID:
+		p.writePlistValue(cfString(UUID(pval).String()))
 	}
 }
 
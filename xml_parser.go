@@ -17,6 +17,8 @@ type xmlPlistParser struct {
 	whitespaceReplacer *strings.Replacer
 	ntags              int
 	idrefs             map[string]cfValue
+	limits             *limitTracker
+	preserveFloat32    bool
 }
 
 func (p *xmlPlistParser) parseDocument() (pval cfValue, parseError error) {
@@ -36,6 +38,9 @@ func (p *xmlPlistParser) parseDocument() (pval cfValue, parseError error) {
 	for {
 		if token, err := p.xmlDecoder.Token(); err == nil {
 			if element, ok := token.(xml.StartElement); ok {
+				if r, ok := p.reader.(*recordingReader); ok {
+					r.stopRecording()
+				}
 				pval = p.parseXMLElement(element)
 				if p.ntags == 0 {
 					panic(invalidPlistError{"XML", errors.New("no elements encountered")})
@@ -64,6 +69,9 @@ func (p *xmlPlistParser) storeOrFindXMLElementValue(element xml.StartElement, va
 
 func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 	var charData xml.CharData
+	if element.Name.Local != "plist" {
+		p.limits.countObject()
+	}
 	switch element.Name.Local {
 	case "plist":
 		p.ntags++
@@ -76,7 +84,11 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 				break
 			}
 			if el, ok := token.(xml.StartElement); ok {
-				return p.parseXMLElement(el)
+				pval := p.parseXMLElement(el)
+				// Consume the rest of the <plist> element, so that a document following this
+				// one in the same stream starts cleanly. A missing </plist> is tolerated.
+				p.xmlDecoder.Skip()
+				return pval
 			}
 		}
 		return nil
@@ -87,6 +99,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 			panic(err)
 		}
 
+		p.limits.countBytes(uint64(len(charData)))
 		return p.storeOrFindXMLElementValue(element, cfString(charData))
 	case "integer":
 		p.ntags++
@@ -101,12 +114,10 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		s := string(charData)
 		if s[0] == '-' {
 			s, base := unsignedGetBase(s[1:])
-			n := mustParseInt("-"+s, base, 64)
-			return p.storeOrFindXMLElementValue(element, &cfNumber{signed: true, value: uint64(n)})
+			return p.storeOrFindXMLElementValue(element, mustParseInteger("-"+s, base))
 		} else {
 			s, base := unsignedGetBase(s)
-			n := mustParseUint(s, base, 64)
-			return p.storeOrFindXMLElementValue(element, &cfNumber{signed: false, value: n})
+			return p.storeOrFindXMLElementValue(element, mustParseInteger(s, base))
 		}
 	case "real":
 		p.ntags++
@@ -117,8 +128,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		if len(charData) == 0 {
 			return p.storeOrFindXMLElementValue(element, &cfReal{wide: true, value: 0})
 		}
-		n := mustParseFloat(string(charData), 64)
-		return p.storeOrFindXMLElementValue(element, &cfReal{wide: true, value: n})
+		return p.storeOrFindXMLElementValue(element, mustParseReal(string(charData), p.preserveFloat32))
 	case "true", "false":
 		p.ntags++
 		p.xmlDecoder.Skip()
@@ -134,7 +144,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		if len(charData) == 0 {
 			return p.storeOrFindXMLElementValue(element, cfDate(time.Time{}))
 		}
-		t, err := time.ParseInLocation(time.RFC3339, string(charData), time.UTC)
+		t, err := parseXMLDate(string(charData))
 		if err != nil {
 			panic(err)
 		}
@@ -148,6 +158,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		if len(charData) == 0 {
 			return p.storeOrFindXMLElementValue(element, cfData(nil))
 		}
+		p.limits.countBytes(uint64(len(charData)))
 		str := p.whitespaceReplacer.Replace(string(charData))
 		l := base64.StdEncoding.DecodedLen(len(str))
 		bytes := make([]uint8, l)
@@ -158,6 +169,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		return p.storeOrFindXMLElementValue(element, cfData(bytes[:l]))
 	case "dict":
 		p.ntags++
+		p.limits.enter()
 		var key *string
 		keys := make([]string, 0, 32)
 		values := make([]cfValue, 0, 32)
@@ -176,21 +188,25 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 				if el.Name.Local == "key" {
 					var k string
 					p.xmlDecoder.DecodeElement(&k, &el)
+					p.limits.countBytes(uint64(len(k)))
 					key = &k
 				} else {
 					if key == nil {
 						panic(errors.New("missing key in dictionary"))
 					}
+					p.limits.checkLength(uint64(len(keys) + 1))
 					keys = append(keys, *key)
 					values = append(values, p.parseXMLElement(el))
 					key = nil
 				}
 			}
 		}
+		p.limits.leave()
 		dict := &cfDictionary{keys: keys, values: values}
 		return p.storeOrFindXMLElementValue(element, dict.maybeUID(false))
 	case "array":
 		p.ntags++
+		p.limits.enter()
 		values := make([]cfValue, 0, 10)
 		for {
 			token, err := p.xmlDecoder.Token()
@@ -201,10 +217,12 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 				break
 			}
 			if el, ok := token.(xml.StartElement); ok {
+				p.limits.checkLength(uint64(len(values) + 1))
 				values = append(values, p.parseXMLElement(el))
 			}
 		}
-		return p.storeOrFindXMLElementValue(element, &cfArray{values})
+		p.limits.leave()
+		return p.storeOrFindXMLElementValue(element, &cfArray{values: values})
 	}
 	err := fmt.Errorf("encountered unknown element %s", element.Name.Local)
 	if p.ntags == 0 {